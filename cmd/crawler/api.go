@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -40,6 +43,12 @@ func startAPI(ctx *cli.Context) error {
 		busyTimeout   = ctx.Uint64(busyTimeoutFlag.Name)
 	)
 
+	// The API may be deployed before the crawler, the tables it reads from
+	// must exist either way.
+	initCrawlerDB := false
+	if _, err := os.Stat(crawlerDBPath); os.IsNotExist(err) {
+		initCrawlerDB = true
+	}
 	crawlerDB, err := openSQLiteDB(
 		crawlerDBPath,
 		autovacuum,
@@ -48,6 +57,15 @@ func startAPI(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	defer crawlerDB.Close()
+	if initCrawlerDB {
+		log.Info("Crawler DB did not exist, init")
+		if err := crawlerdb.CreateDB(crawlerDB); err != nil {
+			return err
+		}
+	} else if err := crawlerdb.UpgradeDB(crawlerDB); err != nil {
+		return err
+	}
 
 	shouldInit := false
 	if _, err := os.Stat(apiDBPath); os.IsNotExist(err) {
@@ -61,13 +79,23 @@ func startAPI(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	defer nodeDB.Close()
 	if shouldInit {
 		log.Info("DB did not exist, init")
 		if err := apidb.CreateDB(nodeDB); err != nil {
 			return err
 		}
+	} else if err := apidb.UpgradeDB(nodeDB); err != nil {
+		return err
 	}
 
+	sigCtx, stop := signal.NotifyContext(ctx.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// If any of the daemons exit, bring down the others as well.
+	daemonCtx, cancel := context.WithCancel(sigCtx)
+	defer cancel()
+
 	// Start daemons
 	var wg sync.WaitGroup
 	wg.Add(3)
@@ -75,23 +103,28 @@ func startAPI(ctx *cli.Context) error {
 	// Start reading daemon
 	go func() {
 		defer wg.Done()
-		newNodeDaemon(crawlerDB, nodeDB)
+		defer cancel()
+		newNodeDaemon(daemonCtx, crawlerDB, nodeDB)
 	}()
 	// Start the drop daemon
 	go func() {
 		defer wg.Done()
-		dropDaemon(nodeDB, ctx.Duration(dropNodesTimeFlag.Name))
+		defer cancel()
+		dropDaemon(daemonCtx, nodeDB, ctx.Duration(dropNodesTimeFlag.Name))
 	}()
 	// Start the API deamon
+	var apiErr error
 	apiAddress := ctx.String(apiListenAddrFlag.Name)
 	apiDaemon := api.New(apiAddress, nodeDB)
 	go func() {
 		defer wg.Done()
-		apiDaemon.HandleRequests()
+		defer cancel()
+		apiErr = apiDaemon.HandleRequests(daemonCtx)
 	}()
 	wg.Wait()
+	log.Info("API stopped")
 
-	return nil
+	return apiErr
 }
 
 func transferNewNodes(crawlerDB, nodeDB *sql.DB) error {
@@ -121,39 +154,84 @@ func transferNewNodes(crawlerDB, nodeDB *sql.DB) error {
 		log.Info("Nodes inserted", "len", len(nodes))
 	}
 
+	attrs, err := crawlerdb.ReadAndDeleteENRAttrs(crawlerDBTx)
+	if err != nil {
+		return fmt.Errorf("error reading ENR attributes: %w", err)
+	}
+	if len(attrs) > 0 {
+		if err := apidb.InsertENRAttrs(nodeDB, attrs); err != nil {
+			return fmt.Errorf("error inserting ENR attributes: %w", err)
+		}
+	}
+
+	estimates, err := crawlerdb.ReadAndDeleteEstimates(crawlerDBTx)
+	if err != nil {
+		return fmt.Errorf("error reading estimates: %w", err)
+	}
+	if len(estimates) > 0 {
+		if err := apidb.InsertEstimates(nodeDB, estimates); err != nil {
+			return fmt.Errorf("error inserting estimates: %w", err)
+		}
+	}
+
+	chains, err := crawlerdb.ReadAndDeleteChainCounts(crawlerDBTx)
+	if err != nil {
+		return fmt.Errorf("error reading chain counts: %w", err)
+	}
+	if len(chains) > 0 {
+		if err := apidb.InsertChainCounts(nodeDB, chains); err != nil {
+			return fmt.Errorf("error inserting chain counts: %w", err)
+		}
+	}
+
 	crawlerDBTx.Commit()
 	return nil
 }
 
+// maxRetryTimeout is the longest the node daemon waits after failures.
+const maxRetryTimeout = 30 * time.Minute
+
 // newNodeDaemon reads new nodes from the crawler and puts them in the db
 // Might trigger the invalidation of caches for the api in the future
-func newNodeDaemon(crawlerDB, nodeDB *sql.DB) {
+func newNodeDaemon(ctx context.Context, crawlerDB, nodeDB *sql.DB) {
 	// Exponentially increase the backoff time
 	retryTimeout := time.Minute
 
 	for {
+		wait := time.Second
+
 		err := transferNewNodes(crawlerDB, nodeDB)
 		if err != nil {
 			log.Error("Failure in transferring new nodes", "err", err)
-			time.Sleep(retryTimeout)
-			retryTimeout *= 2
-			continue
+			wait = retryTimeout
+			retryTimeout = min(2*retryTimeout, maxRetryTimeout)
+		} else {
+			retryTimeout = time.Minute
 		}
 
-		retryTimeout = time.Minute
-		time.Sleep(time.Second)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
-func dropDaemon(db *sql.DB, dropTimeout time.Duration) {
+// dropDaemon drops the nodes which were not crawled for dropTimeout, until
+// the context is cancelled.
+func dropDaemon(ctx context.Context, db *sql.DB, dropTimeout time.Duration) {
 	ticker := time.NewTicker(10 * time.Minute)
 	defer ticker.Stop()
 
 	for {
-		<-ticker.C
-		err := apidb.DropOldNodes(db, dropTimeout)
-		if err != nil {
-			panic(err)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		// Errors are usually locks, the nodes are dropped on the next tick.
+		if err := apidb.DropOldNodes(db, dropTimeout); err != nil {
+			log.Error("Failure in dropping old nodes", "err", err)
 		}
 	}
 }
@@ -17,20 +17,30 @@
 package main
 
 import (
+	"crypto/ecdsa"
 	"database/sql"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	_ "modernc.org/sqlite"
 
 	"github.com/oschwald/geoip2-golang"
 
-	"github.com/ethereum/go-ethereum/cmd/utils"
 	gethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/node-crawler/pkg/common"
 	"github.com/ethereum/node-crawler/pkg/crawler"
 	"github.com/ethereum/node-crawler/pkg/crawlerdb"
+	"github.com/ethereum/node-crawler/pkg/estimate"
+	"github.com/ethereum/node-crawler/pkg/networks"
+	"github.com/ethereum/node-crawler/pkg/peergraph"
 
 	"github.com/urfave/cli/v2"
 )
@@ -44,25 +54,50 @@ var (
 			autovacuumFlag,
 			bootnodesFlag,
 			busyTimeoutFlag,
+			chainsFileFlag,
 			crawlerDBFlag,
+			genesisFlag,
 			geoipdbFlag,
+			geoipASNdbFlag,
+			graphDirFlag,
+			graphFormatFlag,
+			hoodiFlag,
 			listenAddrFlag,
+			networkFlag,
+			networkIDFlag,
 			nodeFileFlag,
 			nodeURLFlag,
 			nodedbFlag,
 			nodekeyFlag,
 			timeoutFlag,
 			workersFlag,
-			utils.HoodiFlag,
-			utils.NetworkIdFlag,
-			utils.SepoliaFlag,
+			deepCrawlFlag,
+			dialFallbackFlag,
+			dialENRLessFlag,
+			dialQueueSizeFlag,
+			forkFilterFlag,
+			scoringFlag,
+			sepoliaFlag,
+			snapProbeFlag,
+			clientNameFlag,
+			retryDelayFlag,
+			retryMaxDelayFlag,
+			rlpxKeyFileFlag,
+			rlpxListenPortFlag,
+			rlpxNodekeyFlag,
 		},
 	}
 )
 
 func crawlNodes(ctx *cli.Context) error {
 	var inputSet common.NodeSet
-	var geoipDB *geoip2.Reader
+	var geoipDB, asnDB *geoip2.Reader
+
+	network, err := loadNetwork(ctx)
+	if err != nil {
+		return err
+	}
+	log.Info("Crawling network", "name", network.Name, "network_id", network.NetworkID, "genesis", network.GenesisHash)
 
 	nodesFile := ctx.String(nodeFileFlag.Name)
 
@@ -87,12 +122,15 @@ func crawlNodes(ctx *cli.Context) error {
 		if err != nil {
 			panic(err)
 		}
+		defer db.Close()
 		log.Info("Connected to db")
 		if shouldInit {
 			log.Info("DB did not exist, init")
 			if err := crawlerdb.CreateDB(db); err != nil {
 				panic(err)
 			}
+		} else if err := crawlerdb.UpgradeDB(db); err != nil {
+			panic(err)
 		}
 	}
 
@@ -100,6 +138,7 @@ func crawlNodes(ctx *cli.Context) error {
 	if err != nil {
 		panic(err)
 	}
+	defer nodeDB.Close()
 
 	if geoipFile := ctx.String(geoipdbFlag.Name); geoipFile != "" {
 		geoipDB, err = geoip2.Open(geoipFile)
@@ -108,24 +147,157 @@ func crawlNodes(ctx *cli.Context) error {
 		}
 		defer func() { _ = geoipDB.Close() }()
 	}
+	if asnFile := ctx.String(geoipASNdbFlag.Name); asnFile != "" {
+		asnDB, err = geoip2.Open(asnFile)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = asnDB.Close() }()
+	}
+
+	// Without a node, we announce the genesis block as our head.
+	var status crawler.StatusProvider
+	if nodeURL := ctx.String(nodeURLFlag.Name); nodeURL != "" {
+		rpcStatus, err := crawler.NewRPCStatus(nodeURL)
+		if err != nil {
+			return err
+		}
+		defer rpcStatus.Close()
+		status = crawler.NewCachedStatus(rpcStatus, 15*time.Second)
+	}
+
+	rlpxKey, err := loadRLPxKey(ctx)
+	if err != nil {
+		return err
+	}
+	scoring, err := crawler.NewScoringPolicy(ctx.String(scoringFlag.Name))
+	if err != nil {
+		return err
+	}
+	forkFilter, err := crawler.ParseForkFilter(ctx.String(forkFilterFlag.Name))
+	if err != nil {
+		return err
+	}
+	chains, err := networks.NewRegistry(ctx.String(chainsFileFlag.Name))
+	if err != nil {
+		return err
+	}
+	graphFormat := ctx.String(graphFormatFlag.Name)
+	if !slices.Contains(peergraph.Formats, graphFormat) {
+		return fmt.Errorf("unknown graph format %q", graphFormat)
+	}
 
 	crawler := crawler.Crawler{
-		NetworkID:  ctx.Uint64(utils.NetworkIdFlag.Name),
-		NodeURL:    ctx.String(nodeURLFlag.Name),
+		Network:    network,
+		NetworkID:  ctx.Uint64(networkIDFlag.Name),
 		ListenAddr: ctx.String(listenAddrFlag.Name),
 		NodeKey:    ctx.String(nodekeyFlag.Name),
 		Bootnodes:  ctx.StringSlice(bootnodesFlag.Name),
 		Timeout:    ctx.Duration(timeoutFlag.Name),
 		Workers:    ctx.Uint64(workersFlag.Name),
-		Sepolia:    ctx.Bool(utils.SepoliaFlag.Name),
-		Hoodi:      ctx.Bool(utils.HoodiFlag.Name),
 		NodeDB:     nodeDB,
+		Status:     status,
+		Estimator:  new(estimate.Rounds),
+		Chains:     chains,
+
+		DialFallback:  ctx.Bool(dialFallbackFlag.Name),
+		DialENRLess:   ctx.Bool(dialENRLessFlag.Name),
+		DialQueueSize: ctx.Int(dialQueueSizeFlag.Name),
+		RetryDelay:    ctx.Duration(retryDelayFlag.Name),
+		RetryMaxDelay: ctx.Duration(retryMaxDelayFlag.Name),
+		ForkFilter:    forkFilter,
+		Scoring:       scoring,
+		DeepCrawl:     ctx.Bool(deepCrawlFlag.Name),
+		GraphDir:      ctx.String(graphDirFlag.Name),
+		GraphFormat:   graphFormat,
+		SnapProbe:     ctx.Bool(snapProbeFlag.Name),
+		RLPxKey:       rlpxKey,
+		ClientName:    ctx.String(clientNameFlag.Name),
+		ListenPort:    ctx.Uint64(rlpxListenPortFlag.Name),
 	}
 
-	for {
-		updatedSet := crawler.CrawlRound(inputSet, db, geoipDB)
+	sigCtx, stop := signal.NotifyContext(ctx.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for sigCtx.Err() == nil {
+		updatedSet, err := crawler.CrawlRound(sigCtx, inputSet, db, geoipDB, asnDB)
+		// Always write out what we have, even if the round was interrupted
+		// or failed to write to the database.
 		if nodesFile != "" {
 			updatedSet.WriteNodesJSON(nodesFile)
 		}
+		if err != nil {
+			return err
+		}
+		// The next round revalidates the nodes of this one, and retries
+		// the busy ones once their backoff expires.
+		inputSet = updatedSet
+	}
+	log.Info("Crawler stopped")
+
+	return nil
+}
+
+// loadRLPxKey returns the key selected by the flags for RLPx connections, or
+// nil if every connection should use a new key.
+func loadRLPxKey(ctx *cli.Context) (*ecdsa.PrivateKey, error) {
+	keyFile := ctx.String(rlpxKeyFileFlag.Name)
+
+	switch {
+	case ctx.Bool(rlpxNodekeyFlag.Name) && keyFile != "":
+		return nil, fmt.Errorf("--%s and --%s are mutually exclusive", rlpxNodekeyFlag.Name, rlpxKeyFileFlag.Name)
+	case ctx.Bool(rlpxNodekeyFlag.Name):
+		nodekey := ctx.String(nodekeyFlag.Name)
+		if nodekey == "" {
+			return nil, fmt.Errorf("--%s requires --%s", rlpxNodekeyFlag.Name, nodekeyFlag.Name)
+		}
+		key, err := crypto.HexToECDSA(nodekey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node key: %w", err)
+		}
+		return key, nil
+	case keyFile != "":
+		key, err := crypto.LoadECDSA(keyFile)
+		if err == nil {
+			return key, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("cannot load RLPx key: %w", err)
+		}
+
+		key, err = crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		if err := crypto.SaveECDSA(keyFile, key); err != nil {
+			return nil, fmt.Errorf("cannot save RLPx key: %w", err)
+		}
+		log.Info("Created new RLPx key", "file", keyFile)
+		return key, nil
+	default:
+		return nil, nil
+	}
+}
+
+// loadNetwork returns the profile of the network selected by the flags.
+// The deprecated network flags select the network of the same name.
+func loadNetwork(ctx *cli.Context) (*networks.Profile, error) {
+	if genesis := ctx.String(genesisFlag.Name); genesis != "" {
+		return networks.LoadGenesis(genesis)
+	}
+	var (
+		name     = ctx.String(networkFlag.Name)
+		selected = ctx.IsSet(networkFlag.Name)
+	)
+	for _, flag := range []*cli.BoolFlag{sepoliaFlag, hoodiFlag} {
+		if !ctx.Bool(flag.Name) {
+			continue
+		}
+		if selected && name != flag.Name {
+			return nil, fmt.Errorf("--%s conflicts with the selected network %s", flag.Name, name)
+		}
+		log.Warn(fmt.Sprintf("--%s is deprecated, use --network %s", flag.Name, flag.Name))
+		name, selected = flag.Name, true
 	}
+	return networks.Lookup(name)
 }
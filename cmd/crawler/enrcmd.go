@@ -0,0 +1,475 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	gethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/node-crawler/pkg/apidb"
+	"github.com/ethereum/node-crawler/pkg/common"
+	"github.com/ethereum/node-crawler/pkg/crawler"
+	"github.com/ethereum/node-crawler/pkg/crawlerdb"
+	"github.com/ethereum/node-crawler/pkg/enrattr"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	enrCommand = &cli.Command{
+		Name:  "enr",
+		Usage: "Decode node records and inspect what the crawler knows about nodes",
+		Subcommands: []*cli.Command{
+			enrDecodeCommand,
+			enrLookupCommand,
+			enrDiffCommand,
+		},
+	}
+	enrDecodeCommand = &cli.Command{
+		Name:      "decode",
+		Usage:     "Verify and print a node record",
+		ArgsUsage: "<record>",
+		Description: `The record is an enr: string, hex, base64, an enode:// URL, or the name
+of a file holding any of them. It is read from stdin if it is "-" or missing.`,
+		Action: enrDecode,
+		Flags:  []cli.Flag{jsonFlag},
+	}
+	enrLookupCommand = &cli.Command{
+		Name:      "lookup",
+		Usage:     "Print what the crawler knows about a node",
+		ArgsUsage: "<node ID or record>",
+		Description: `Prints the state of the node in the node file, which is the crawler's view
+of the network, the rows of the crawler DB which were not transferred to the
+API yet, and the row and record of the node in the API DB.`,
+		Action: enrLookup,
+		Flags:  []cli.Flag{enrAPIDBFlag, enrCrawlerDBFlag, jsonFlag, nodeFileFlag},
+	}
+	enrDiffCommand = &cli.Command{
+		Name:      "diff",
+		Usage:     "Compare a node record with another one, or with the one known to the crawler",
+		ArgsUsage: "<record> [<record>]",
+		Action:    enrDiff,
+		Flags:     []cli.Flag{enrAPIDBFlag, enrCrawlerDBFlag, jsonFlag, nodeFileFlag},
+	}
+)
+
+// decodedRecord is the JSON output of enr decode.
+type decodedRecord struct {
+	ID enode.ID `json:"id"`
+	// Record is empty for enode URLs.
+	Record    string         `json:"record,omitempty"`
+	Seq       uint64         `json:"seq"`
+	Signature string         `json:"signature"`
+	Attrs     []enrattr.Attr `json:"attributes"`
+}
+
+func enrDecode(ctx *cli.Context) error {
+	source, err := readRecordArg(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	n, r, sigErr := parseRecordArg(source)
+	if n == nil && r == nil {
+		return sigErr
+	}
+
+	dec := decodedRecord{Seq: r.Seq(), Signature: "valid", Attrs: enrattr.Decode(r)}
+	switch {
+	case sigErr != nil:
+		dec.Signature = sigErr.Error()
+	case strings.HasPrefix(source, "enode://"):
+		dec.ID, dec.Signature = n.ID(), "none (enode URL)"
+	default:
+		dec.ID, dec.Record = n.ID(), n.String()
+	}
+
+	if ctx.Bool(jsonFlag.Name) {
+		return writeJSON(ctx.App.Writer, dec)
+	}
+	w := ctx.App.Writer
+	if sigErr == nil {
+		fmt.Fprintf(w, "Node ID: %v\n", dec.ID)
+	}
+	fmt.Fprintf(w, "Signature: %s\n", dec.Signature)
+	fmt.Fprintf(w, "Record has sequence number %d and %d key/value pairs.\n", dec.Seq, len(dec.Attrs))
+	return writeAttrs(w, dec.Attrs)
+}
+
+// readRecordArg returns the record given on the command line. The argument
+// is read from a file if there is one of its name, and from stdin if it is
+// empty or "-".
+func readRecordArg(arg string) (string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	switch {
+	case arg == "" || arg == "-":
+		data, err = io.ReadAll(os.Stdin)
+	case gethCommon.FileExist(arg):
+		data, err = os.ReadFile(arg)
+	default:
+		return strings.TrimSpace(arg), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// parseRecordArg parses a record, and verifies its signature. If only the
+// signature is invalid, the record is returned with the error, and the
+// node is nil.
+func parseRecordArg(source string) (*enode.Node, *enr.Record, error) {
+	if strings.HasPrefix(source, "enode://") {
+		n, err := crawler.ParseNode(source)
+		if err != nil {
+			return nil, nil, err
+		}
+		return n, n.Record(), nil
+	}
+	r, err := crawler.ParseRecord(source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid record: %w", err)
+	}
+	n, err := enode.New(enode.ValidSchemes, r)
+	if err != nil {
+		return nil, r, fmt.Errorf("invalid signature: %w", err)
+	}
+	return n, r, nil
+}
+
+func writeAttrs(w io.Writer, attrs []enrattr.Attr) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, a := range attrs {
+		value := a.Value
+		if !a.Decoded {
+			value += " (raw)"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", a.Key, value)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// lookupResult is the JSON output of enr lookup.
+type lookupResult struct {
+	ID enode.ID `json:"id"`
+	// Node is the node in the node file, nil if it is not there.
+	Node *common.NodeJSON `json:"node"`
+	// Rows and Attrs are the rows of the crawler DB.
+	Rows  []crawlerdb.CrawledNode `json:"rows"`
+	Attrs []crawlerdb.ENRAttr     `json:"attributes"`
+	// APINode and APIAttrs are the rows of the API DB. APINode is nil if
+	// the node is not there.
+	APINode  *apidb.Node         `json:"apiNode"`
+	APIAttrs []crawlerdb.ENRAttr `json:"apiAttributes"`
+}
+
+func enrLookup(ctx *cli.Context) error {
+	id, err := parseNodeID(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	res, err := lookupNode(ctx, id)
+	if err != nil {
+		return err
+	}
+	if ctx.Bool(jsonFlag.Name) {
+		return writeJSON(ctx.App.Writer, res)
+	}
+
+	w := ctx.App.Writer
+	fmt.Fprintf(w, "Node ID: %v\n", id)
+	if ctx.IsSet(nodeFileFlag.Name) {
+		if res.Node == nil {
+			fmt.Fprintln(w, "Not in the node file: the node was never found, or was dropped after failing its liveness checks.")
+		} else {
+			writeNodeState(w, *res.Node)
+		}
+	}
+	if ctx.IsSet(enrCrawlerDBFlag.Name) {
+		fmt.Fprintf(w, "Crawler DB: %d rows not transferred to the API yet.\n", len(res.Rows))
+		for _, row := range res.Rows {
+			writeCrawledNode(w, row)
+		}
+		if len(res.Attrs) > 0 {
+			fmt.Fprintf(w, "Latest record in the crawler DB, sequence number %d:\n", res.Attrs[0].Seq)
+			if err := writeAttrs(w, storedAttrs(res.Attrs)); err != nil {
+				return err
+			}
+		}
+	}
+	if ctx.IsSet(enrAPIDBFlag.Name) {
+		if res.APINode == nil {
+			fmt.Fprintln(w, "Not in the API DB: the node was never identified, or was dropped.")
+		} else {
+			writeAPINode(w, *res.APINode)
+		}
+		if len(res.APIAttrs) > 0 {
+			fmt.Fprintf(w, "Record in the API DB, sequence number %d, last seen %s:\n", res.APIAttrs[0].Seq, res.APIAttrs[0].Now)
+			return writeAttrs(w, storedAttrs(res.APIAttrs))
+		}
+	}
+	return nil
+}
+
+func storedAttrs(stored []crawlerdb.ENRAttr) []enrattr.Attr {
+	attrs := make([]enrattr.Attr, len(stored))
+	for i, a := range stored {
+		attrs[i] = enrattr.Attr{Key: a.Key, Value: a.Value, Decoded: a.Decoded}
+	}
+	return attrs
+}
+
+// lookupNode reads what the node file, the crawler DB and the API DB know
+// about the node.
+func lookupNode(ctx *cli.Context, id enode.ID) (*lookupResult, error) {
+	if !ctx.IsSet(nodeFileFlag.Name) && !ctx.IsSet(enrCrawlerDBFlag.Name) && !ctx.IsSet(enrAPIDBFlag.Name) {
+		return nil, fmt.Errorf("need --%s, --%s or --%s", nodeFileFlag.Name, enrCrawlerDBFlag.Name, enrAPIDBFlag.Name)
+	}
+	res := &lookupResult{ID: id}
+	if file := ctx.String(nodeFileFlag.Name); file != "" {
+		if !gethCommon.FileExist(file) {
+			return nil, fmt.Errorf("node file %s does not exist", file)
+		}
+		if n, ok := common.LoadNodesJSON(file)[id]; ok {
+			res.Node = &n
+		}
+	}
+	if name := ctx.String(enrCrawlerDBFlag.Name); name != "" {
+		db, err := openReadOnlyDB(name)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		if res.Rows, err = crawlerdb.ReadNode(db, id.String()); err != nil {
+			return nil, fmt.Errorf("error reading node: %w", err)
+		}
+		if res.Attrs, err = crawlerdb.ReadENRAttrs(db, id.String()); err != nil {
+			return nil, fmt.Errorf("error reading ENR attributes: %w", err)
+		}
+	}
+	if name := ctx.String(enrAPIDBFlag.Name); name != "" {
+		db, err := openReadOnlyDB(name)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		if res.APINode, err = apidb.ReadNode(db, id.String()); err != nil {
+			return nil, fmt.Errorf("error reading API node: %w", err)
+		}
+		if res.APIAttrs, err = apidb.ReadENRAttrs(db, id.String()); err != nil {
+			return nil, fmt.Errorf("error reading API ENR attributes: %w", err)
+		}
+	}
+	return res, nil
+}
+
+func writeNodeState(w io.Writer, n common.NodeJSON) {
+	fmt.Fprintf(w, "Node file: score %d, sequence number %d\n", n.Score, n.Seq)
+	fmt.Fprintf(w, "  first response %v, last response %v, last check %v\n",
+		formatTime(n.FirstResponse), formatTime(n.LastResponse), formatTime(n.LastCheck))
+	protocols := make([]string, 0, len(n.Discovery))
+	for p := range n.Discovery {
+		protocols = append(protocols, p)
+	}
+	sort.Strings(protocols)
+	for _, p := range protocols {
+		d := n.Discovery[p]
+		fmt.Fprintf(w, "  %s: found %t, alive %t, last check %v\n", p, d.Found, d.Alive(), formatTime(d.LastCheck))
+	}
+	if n.Info != nil {
+		fmt.Fprintf(w, "  client %q, network %d\n", n.Info.ClientType, n.Info.NetworkID)
+	}
+	if n.Failure != nil {
+		fmt.Fprintf(w, "  last dial failed: %v\n", n.Failure)
+	}
+	if n.ENRLess {
+		fmt.Fprintln(w, "  the node does not answer ENR requests")
+	}
+}
+
+func writeAPINode(w io.Writer, n apidb.Node) {
+	fmt.Fprintf(w, "API DB: client %q version %s, source %q, last crawled %s\n", n.Name, n.Version, n.ClientSource, n.LastCrawled)
+	fmt.Fprintf(w, "  country %q, chain %q, fork stage %q\n", n.Country, n.Chain, n.ForkStage)
+	if n.FailureKind != "" {
+		fmt.Fprintf(w, "  last dial failed in %s: %s\n", n.FailurePhase, n.FailureKind)
+	}
+}
+
+func writeCrawledNode(w io.Writer, row crawlerdb.CrawledNode) {
+	fmt.Fprintf(w, "  %s: client %q, network %d, discovery %q", row.Now, row.ClientType, row.NetworkID, row.DiscProtocols)
+	if row.FailureKind != "" {
+		fmt.Fprintf(w, ", failed in %s: %s", row.FailurePhase, row.FailureKind)
+	}
+	fmt.Fprintln(w)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// parseNodeID parses a node ID, or returns the ID of a record.
+func parseNodeID(arg string) (enode.ID, error) {
+	if arg == "" {
+		return enode.ID{}, errors.New("missing node ID")
+	}
+	if id, err := enode.ParseID(arg); err == nil {
+		return id, nil
+	}
+	source, err := readRecordArg(arg)
+	if err != nil {
+		return enode.ID{}, err
+	}
+	n, _, err := parseRecordArg(source)
+	if err != nil {
+		return enode.ID{}, err
+	}
+	return n.ID(), nil
+}
+
+func openReadOnlyDB(name string) (*sql.DB, error) {
+	if !gethCommon.FileExist(name) {
+		return nil, fmt.Errorf("database %s does not exist", name)
+	}
+	db, err := sql.Open("sqlite", "file:"+name+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	return db, nil
+}
+
+// attrChange is a key whose value differs between two records. Old or New
+// is empty if the key is missing in the record.
+type attrChange struct {
+	Key string `json:"key"`
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// diffResult is the JSON output of enr diff.
+type diffResult struct {
+	ID enode.ID `json:"id"`
+	// Source is where the old record is from.
+	Source  string       `json:"source"`
+	OldSeq  uint64       `json:"oldSeq"`
+	NewSeq  uint64       `json:"newSeq"`
+	Changes []attrChange `json:"changes"`
+}
+
+func enrDiff(ctx *cli.Context) error {
+	if ctx.NArg() == 0 || ctx.NArg() > 2 {
+		return errors.New("need one or two records")
+	}
+	newSource, err := readRecordArg(ctx.Args().Get(ctx.NArg() - 1))
+	if err != nil {
+		return err
+	}
+	n, r, err := parseRecordArg(newSource)
+	if err != nil {
+		return err
+	}
+	res := diffResult{ID: n.ID(), NewSeq: r.Seq()}
+
+	var old map[string]string
+	if ctx.NArg() == 2 {
+		oldSource, err := readRecordArg(ctx.Args().First())
+		if err != nil {
+			return err
+		}
+		on, or, err := parseRecordArg(oldSource)
+		if err != nil {
+			return err
+		}
+		if on.ID() != n.ID() {
+			return fmt.Errorf("records of different nodes %v and %v", on.ID(), n.ID())
+		}
+		res.Source, res.OldSeq, old = "record", or.Seq(), attrValues(enrattr.Decode(or))
+	} else {
+		known, err := lookupNode(ctx, n.ID())
+		if err != nil {
+			return err
+		}
+		switch {
+		case known.Node != nil:
+			res.Source, res.OldSeq = "node file", known.Node.N.Seq()
+			old = attrValues(enrattr.Decode(known.Node.N.Record()))
+		case len(known.Attrs) > 0:
+			res.Source, res.OldSeq = "crawler DB", known.Attrs[0].Seq
+			old = attrValues(storedAttrs(known.Attrs))
+		case len(known.APIAttrs) > 0:
+			res.Source, res.OldSeq = "API DB", known.APIAttrs[0].Seq
+			old = attrValues(storedAttrs(known.APIAttrs))
+		default:
+			return fmt.Errorf("node %v is not known to the crawler", n.ID())
+		}
+	}
+	res.Changes = diffAttrs(old, attrValues(enrattr.Decode(r)))
+
+	if ctx.Bool(jsonFlag.Name) {
+		return writeJSON(ctx.App.Writer, res)
+	}
+	w := ctx.App.Writer
+	fmt.Fprintf(w, "Node ID: %v\n", res.ID)
+	fmt.Fprintf(w, "Sequence number %d (%s) -> %d\n", res.OldSeq, res.Source, res.NewSeq)
+	if len(res.Changes) == 0 {
+		fmt.Fprintln(w, "No changes.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, c := range res.Changes {
+		switch {
+		case c.Old == "":
+			fmt.Fprintf(tw, "+ %s\t%s\n", c.Key, c.New)
+		case c.New == "":
+			fmt.Fprintf(tw, "- %s\t%s\n", c.Key, c.Old)
+		default:
+			fmt.Fprintf(tw, "~ %s\t%s -> %s\n", c.Key, c.Old, c.New)
+		}
+	}
+	return tw.Flush()
+}
+
+func attrValues(attrs []enrattr.Attr) map[string]string {
+	values := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		values[a.Key] = a.Value
+	}
+	return values
+}
+
+// diffAttrs returns the changed keys, sorted.
+func diffAttrs(old, new map[string]string) []attrChange {
+	keys := make(map[string]struct{}, len(old)+len(new))
+	for k := range old {
+		keys[k] = struct{}{}
+	}
+	for k := range new {
+		keys[k] = struct{}{}
+	}
+	var changes []attrChange
+	for k := range keys {
+		if old[k] != new[k] {
+			changes = append(changes, attrChange{Key: k, Old: old[k], New: new[k]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"strings"
 	"time"
 
+	"github.com/ethereum/node-crawler/pkg/networks"
 	"github.com/urfave/cli/v2"
 )
 
@@ -35,24 +37,97 @@ var (
 			"https://www.sqlite.org/pragma.html#pragma_busy_timeout"),
 		Value: 3000,
 	}
+	chainsFileFlag = &cli.StringFlag{
+		Name:  "chains-file",
+		Usage: "JSON file of known chains to label the nodes with, in addition to the built-in networks",
+	}
+	clientNameFlag = &cli.StringFlag{
+		Name:  "client-name",
+		Usage: "Client name announced to the nodes we connect to",
+	}
 	crawlerDBFlag = &cli.StringFlag{
 		Name:     "crawler-db",
 		Usage:    "Crawler SQLite file name",
 		Required: true,
 	}
+	deepCrawlFlag = &cli.BoolFlag{
+		Name:  "deep-crawl",
+		Usage: "Query the routing tables of the live nodes with FINDNODE to build the peer graph of every round",
+	}
+	dialFallbackFlag = &cli.BoolFlag{
+		Name:  "dial-fallback",
+		Usage: "Dial the other address family of dual-stack nodes if their preferred address cannot be reached",
+	}
+	dialENRLessFlag = &cli.BoolFlag{
+		Name:  "dial-enrless",
+		Usage: "Dial discv4 nodes which don't answer ENR requests (EIP-868) with the endpoint they were discovered with",
+	}
+	dialQueueSizeFlag = &cli.IntFlag{
+		Name:  "dial-queue-size",
+		Usage: "Maximum number of nodes waiting to be dialed",
+		Value: 512,
+	}
 	dropNodesTimeFlag = &cli.DurationFlag{
 		Name:  "drop-time",
 		Usage: "Time to drop crawled nodes without any updates",
 		Value: 24 * time.Hour,
 	}
+	enrAPIDBFlag = &cli.StringFlag{
+		Name:  "api-db",
+		Usage: "API SQLite file name",
+	}
+	enrCrawlerDBFlag = &cli.StringFlag{
+		Name:  "crawler-db",
+		Usage: "Crawler SQLite file name",
+	}
+	forkFilterFlag = &cli.StringFlag{
+		Name:  "fork-filter",
+		Usage: "Policy for nodes whose record announces the fork ID of another network, 'off', 'skip' (not dialed) or 'deprioritize' (dialed last)",
+		Value: "off",
+	}
+	genesisFlag = &cli.StringFlag{
+		Name:  "genesis",
+		Usage: "Genesis JSON file of a custom network to crawl. Overrides --network",
+	}
+	geoipASNdbFlag = &cli.StringFlag{
+		Name:  "geoip-asn-db",
+		Usage: "geoip2 ASN database location",
+	}
 	geoipdbFlag = &cli.StringFlag{
 		Name:  "geoipdb",
 		Usage: "geoip2 database location",
 	}
+	graphDirFlag = &cli.StringFlag{
+		Name:  "graph-dir",
+		Usage: "Directory to write the peer graph of every round to, with --deep-crawl",
+	}
+	graphFormatFlag = &cli.StringFlag{
+		Name:  "graph-format",
+		Usage: "Format of the peer graph files, 'graphml', 'dot' or 'json'",
+		Value: "graphml",
+	}
+	hoodiFlag = &cli.BoolFlag{
+		Name:   "hoodi",
+		Usage:  "Deprecated, use --network hoodi",
+		Hidden: true,
+	}
+	jsonFlag = &cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print JSON instead of text",
+	}
 	listenAddrFlag = &cli.StringFlag{
 		Name:  "addr",
-		Usage: "Listening address",
-		Value: "0.0.0.0:0",
+		Usage: "Listening address. The default listens on IPv4 and IPv6",
+		Value: ":0",
+	}
+	networkFlag = &cli.StringFlag{
+		Name:  "network",
+		Usage: "Name of the network to crawl (" + strings.Join(networks.Names(), ", ") + ")",
+		Value: "mainnet",
+	}
+	networkIDFlag = &cli.Uint64Flag{
+		Name:  "networkid",
+		Usage: "Network ID to use in the status handshake. Defaults to the network ID of the selected network",
 	}
 	nodedbFlag = &cli.StringFlag{
 		Name:  "nodedb",
@@ -71,6 +146,42 @@ var (
 		Usage: "URL of the node you want to connect to",
 		// Value: "http://localhost:8545",
 	}
+	retryDelayFlag = &cli.DurationFlag{
+		Name:  "retry-delay",
+		Usage: "Initial delay before dialing nodes again which have too many peers, doubled with every attempt (0 = no retries)",
+		Value: 30 * time.Second,
+	}
+	retryMaxDelayFlag = &cli.DurationFlag{
+		Name:  "retry-max-delay",
+		Usage: "Maximum delay before dialing nodes again which have too many peers",
+		Value: 30 * time.Minute,
+	}
+	rlpxKeyFileFlag = &cli.StringFlag{
+		Name:  "rlpx-keyfile",
+		Usage: "File of the key used for RLPx connections. A new key is saved to the file if it does not exist",
+	}
+	rlpxListenPortFlag = &cli.Uint64Flag{
+		Name:  "rlpx-listen-port",
+		Usage: "Listening port announced to the nodes we connect to",
+	}
+	rlpxNodekeyFlag = &cli.BoolFlag{
+		Name:  "rlpx-nodekey",
+		Usage: "Use the --nodekey identity for RLPx connections. By default, every connection uses a new key",
+	}
+	sepoliaFlag = &cli.BoolFlag{
+		Name:   "sepolia",
+		Usage:  "Deprecated, use --network sepolia",
+		Hidden: true,
+	}
+	scoringFlag = &cli.StringFlag{
+		Name:  "scoring",
+		Usage: "Node scoring policy, 'default' (counts liveness checks) or 'uptime' (moving average of ENR responses)",
+		Value: "default",
+	}
+	snapProbeFlag = &cli.BoolFlag{
+		Name:  "snap-probe",
+		Usage: "Request a small amount of snap data from nodes to check whether they serve it",
+	}
 	timeoutFlag = &cli.DurationFlag{
 		Name:  "timeout",
 		Usage: "Timeout for the crawling in a round",
@@ -78,7 +189,7 @@ var (
 	}
 	workersFlag = &cli.Uint64Flag{
 		Name:  "workers",
-		Usage: "Number of workers to start for updating nodes",
+		Usage: "Number of workers requesting node records, and of workers dialing nodes",
 		Value: 16,
 	}
 )
@@ -43,6 +43,7 @@ func init() {
 	app.Commands = []*cli.Command{
 		apiCommand,
 		crawlerCommand,
+		enrCommand,
 	}
 }
 
@@ -188,7 +188,7 @@
               network = {
                 type = types.str;
                 default = "mainnet";
-                example = "goerli";
+                example = "sepolia";
                 description = "Name of the network to crawl. Defaults to Mainnet.";
               };
             };
@@ -207,9 +207,8 @@
                     args = [
                       "--crawler-db=${cfg.crawlerDatabaseName}"
                       "--geoipdb=${cfg.crawler.geoipdb}"
-                    ]
-                    ++ optional (cfg.crawler.network == "goerli") "--goerli"
-                    ++ optional (cfg.crawler.network == "sepolia") "--sepolia";
+                      "--network=${cfg.crawler.network}"
+                    ];
                   in
                   "${pkgs.nodeCrawler}/bin/crawler crawl ${concatStringsSep " " args}";
 
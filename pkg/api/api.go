@@ -1,8 +1,10 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -48,13 +50,51 @@ func (a *Api) dropCacheLoop() {
 	}
 }
 
-func (a *Api) HandleRequests() {
+// HandleRequests serves the API until the context is cancelled, after which
+// the server is shut down, giving in-flight requests a few seconds to finish.
+func (a *Api) HandleRequests(ctx context.Context) error {
 	router := mux.NewRouter().StrictSlash(true)
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("Hello")) })
 	router.HandleFunc("/v1/dashboard", a.handleDashboard).Queries("filter", "{filter}")
 	router.HandleFunc("/v1/dashboard", a.handleDashboard)
+	router.HandleFunc("/v1/failures", a.handleFailures).Queries("filter", "{filter}")
+	router.HandleFunc("/v1/failures", a.handleFailures)
+	router.HandleFunc("/v1/latency/{group}", a.handleLatency).Queries("filter", "{filter}")
+	router.HandleFunc("/v1/latency/{group}", a.handleLatency)
+	router.HandleFunc("/v1/discovery", a.handleDiscovery).Queries("filter", "{filter}")
+	router.HandleFunc("/v1/discovery", a.handleDiscovery)
+	router.HandleFunc("/v1/estimates", a.handleEstimates)
+	router.HandleFunc("/v1/estimates/{client}", a.handleClientEstimates)
+	router.HandleFunc("/v1/nodes/{id}/enr", a.handleNodeENR)
+	router.HandleFunc("/v1/chains", a.handleChains)
+
+	srv := &http.Server{
+		Addr:    a.address,
+		Handler: router,
+	}
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		log.Info("Stopping API", "address", a.address)
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error("Failure in shutting down the API", "err", err)
+		}
+	}()
+
 	log.Info("Starting API", "address", a.address)
-	http.ListenAndServe(a.address, router)
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-shutdownDone
+
+	return nil
 }
 
 type client struct {
@@ -305,19 +345,47 @@ func clientQuery(db *sql.DB, query string, args ...interface{}) ([]client, error
 
 func validateKey(key string) bool {
 	validKeys := map[string]struct{}{
-		"id":               {},
-		"name":             {},
-		"version_major":    {},
-		"version_minor":    {},
-		"version_patch":    {},
-		"version_tag":      {},
-		"version_build":    {},
-		"version_date":     {},
-		"os_name":          {},
-		"os_architecture":  {},
-		"language_name":    {},
-		"language_version": {},
-		"country":          {},
+		"id":                 {},
+		"name":               {},
+		"version_major":      {},
+		"version_minor":      {},
+		"version_patch":      {},
+		"version_tag":        {},
+		"version_build":      {},
+		"version_date":       {},
+		"os_name":            {},
+		"os_architecture":    {},
+		"language_name":      {},
+		"language_version":   {},
+		"country":            {},
+		"ip_family":          {},
+		"earliest_block":     {},
+		"latest_block":       {},
+		"block_height":       {},
+		"head_time":          {},
+		"fork_compat":        {},
+		"snap_answered":      {},
+		"snap_latency":       {},
+		"snap_response_size": {},
+		"failure_phase":      {},
+		"failure_kind":       {},
+		"disc_reason":        {},
+		"asn":                {},
+		"asn_org":            {},
+		"enr_latency":        {},
+		"connect_latency":    {},
+		"handshake_latency":  {},
+		"hello_latency":      {},
+		"status_latency":     {},
+		"dial_attempts":      {},
+		"disc_protocols":     {},
+		"discv4_alive":       {},
+		"discv5_alive":       {},
+		"eip868":             {},
+		"enrless":            {},
+		"client_source":      {},
+		"chain":              {},
+		"fork_stage":         {},
 	}
 	_, ok := validKeys[key]
 	return ok
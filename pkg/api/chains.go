@@ -0,0 +1,95 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// chainShare is the number of nodes of a chain in a round, their share of
+// all nodes, and their number at every fork stage.
+type chainShare struct {
+	Name   string   `json:"name"`
+	Count  int      `json:"count"`
+	Share  float64  `json:"share"`
+	Stages []client `json:"stages"`
+}
+
+type chainsResult struct {
+	Round  string       `json:"round"`
+	Total  int          `json:"total"`
+	Chains []chainShare `json:"chains"`
+}
+
+// handleChains returns the breakdown of the nodes of the latest round by the
+// chain they are on.
+func (a *Api) handleChains(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "max-age=600")
+
+	var (
+		res *chainsResult
+		err error
+	)
+	if cached, ok := a.cache.Get("ch"); ok {
+		res = cached.(*chainsResult)
+	} else {
+		res, err = chainsQuery(a.db)
+		if err != nil {
+			log.Error("Failure in the query", "err", err)
+			http.Error(rw, "query failed", http.StatusInternalServerError)
+			return
+		}
+		a.cache.Add("ch", res)
+	}
+	json.NewEncoder(rw).Encode(res)
+}
+
+func chainsQuery(db *sql.DB) (*chainsResult, error) {
+	rows, err := db.Query(`
+		SELECT round, chain, fork_stage, nodes
+		FROM chain_counts
+		WHERE round = (SELECT MAX(round) FROM chain_counts)
+		ORDER BY nodes DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		res   = new(chainsResult)
+		index = make(map[string]int)
+	)
+	for rows.Next() {
+		var (
+			chain, stage string
+			nodes        int
+		)
+		if err := rows.Scan(&res.Round, &chain, &stage, &nodes); err != nil {
+			return nil, err
+		}
+		i, ok := index[chain]
+		if !ok {
+			i = len(res.Chains)
+			index[chain] = i
+			res.Chains = append(res.Chains, chainShare{Name: chain})
+		}
+		res.Chains[i].Count += nodes
+		res.Chains[i].Stages = append(res.Chains[i].Stages, client{Name: stage, Count: nodes})
+		res.Total += nodes
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range res.Chains {
+		res.Chains[i].Share = float64(res.Chains[i].Count) / float64(res.Total)
+	}
+	sort.SliceStable(res.Chains, func(i, j int) bool {
+		return res.Chains[i].Count > res.Chains[j].Count
+	})
+	return res, nil
+}
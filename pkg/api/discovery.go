@@ -0,0 +1,88 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gorilla/mux"
+)
+
+// discoveryAdoption counts the nodes of a client by discovery protocol.
+type discoveryAdoption struct {
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+	DiscV4 int    `json:"discv4"`
+	DiscV5 int    `json:"discv5"`
+	Both   int    `json:"both"`
+	// EIP868 counts the nodes answering discv4 ENR requests.
+	EIP868 int `json:"eip868"`
+}
+
+type discoveryResult struct {
+	Clients []discoveryAdoption `json:"clients"`
+}
+
+// handleDiscovery returns which discovery protocols found the nodes of every
+// client, to track the adoption of discv5.
+func (a *Api) handleDiscovery(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "max-age=600")
+
+	where, whereArgs, err := addFilterArgs(mux.Vars(r))
+	if err != nil {
+		log.Error("Failure when adding filter to the query", "err", err)
+		return
+	}
+	if whereArgs != nil {
+		where = "WHERE " + where
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			name,
+			COUNT(*) as Count,
+			SUM(disc_protocols LIKE '%%discv4%%'),
+			SUM(disc_protocols LIKE '%%discv5%%'),
+			SUM(disc_protocols = 'discv4,discv5'),
+			SUM(COALESCE(eip868, 0))
+		FROM nodes %v
+		GROUP BY name
+		ORDER BY Count DESC
+	`, where)
+
+	var res discoveryResult
+	if cached, ok := a.cache.Get("d" + toQuery(query, whereArgs)); ok {
+		res.Clients = cached.([]discoveryAdoption)
+	} else {
+		res.Clients, err = discoveryQuery(a.db, query, whereArgs...)
+		if err != nil {
+			log.Error("Failure in the query", "err", err)
+		}
+		a.cache.Add("d"+toQuery(query, whereArgs), res.Clients)
+	}
+	json.NewEncoder(rw).Encode(res)
+}
+
+func discoveryQuery(db *sql.DB, query string, args ...interface{}) ([]discoveryAdoption, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []discoveryAdoption
+	for rows.Next() {
+		var (
+			c                    discoveryAdoption
+			v4, v5, both, eip868 sql.NullInt64
+		)
+		if err := rows.Scan(&c.Name, &c.Count, &v4, &v5, &both, &eip868); err != nil {
+			return nil, err
+		}
+		c.DiscV4, c.DiscV5, c.Both, c.EIP868 = int(v4.Int64), int(v5.Int64), int(both.Int64), int(eip868.Int64)
+		clients = append(clients, c)
+	}
+	return clients, rows.Err()
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gorilla/mux"
+)
+
+// enrAttribute is a key/value pair of a node record. The value is decoded
+// if decoded is set, and hex encoded otherwise. Raw is the hex encoded RLP
+// value.
+type enrAttribute struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Raw     string `json:"raw"`
+	Decoded bool   `json:"decoded"`
+}
+
+type enrResult struct {
+	ID         string         `json:"id"`
+	Seq        uint64         `json:"seq"`
+	Attributes []enrAttribute `json:"attributes"`
+}
+
+// handleNodeENR returns the key/value pairs of the latest record of a node.
+func (a *Api) handleNodeENR(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "max-age=600")
+
+	id := strings.ToLower(mux.Vars(r)["id"])
+
+	var (
+		res *enrResult
+		err error
+	)
+	if cached, ok := a.cache.Get("enr" + id); ok {
+		res = cached.(*enrResult)
+	} else {
+		res, err = enrQuery(a.db, id)
+		if err != nil {
+			log.Error("Failure in the query", "err", err)
+			http.Error(rw, "query failed", http.StatusInternalServerError)
+			return
+		}
+		a.cache.Add("enr"+id, res)
+	}
+	if res == nil {
+		http.Error(rw, "unknown node", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(rw).Encode(res)
+}
+
+// enrQuery returns the record of the node, or nil if it is not known.
+func enrQuery(db *sql.DB, id string) (*enrResult, error) {
+	rows, err := db.Query(`
+		SELECT seq, key, value, raw, decoded
+		FROM enr_attributes
+		WHERE id = ?
+		ORDER BY key
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res *enrResult
+	for rows.Next() {
+		var (
+			attr enrAttribute
+			seq  uint64
+		)
+		if err := rows.Scan(&seq, &attr.Key, &attr.Value, &attr.Raw, &attr.Decoded); err != nil {
+			return nil, err
+		}
+		if res == nil {
+			res = &enrResult{ID: id}
+		}
+		res.Seq = max(res.Seq, seq)
+		res.Attributes = append(res.Attributes, attr)
+	}
+	return res, rows.Err()
+}
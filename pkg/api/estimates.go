@@ -0,0 +1,98 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gorilla/mux"
+)
+
+// sizeEstimate is an estimated number of nodes of a client in a network,
+// with its 95% confidence interval.
+type sizeEstimate struct {
+	Round     string  `json:"round"`
+	Method    string  `json:"method"`
+	Client    string  `json:"client"`
+	NetworkID uint64  `json:"networkId"`
+	First     int     `json:"first"`
+	Second    int     `json:"second"`
+	Both      int     `json:"both"`
+	Size      float64 `json:"size"`
+	Lower     float64 `json:"lower"`
+	Upper     float64 `json:"upper"`
+}
+
+type estimatesResult struct {
+	Estimates []sizeEstimate `json:"estimates"`
+}
+
+const estimatesColumns = `
+	round, method, client, network_id, first, second, both, size, lower, upper
+`
+
+// handleEstimates returns the network size estimates of the latest round.
+func (a *Api) handleEstimates(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "max-age=600")
+
+	query := `
+		SELECT` + estimatesColumns + `
+		FROM estimates
+		WHERE round = (SELECT MAX(round) FROM estimates)
+		ORDER BY network_id, method, client
+	`
+	a.serveEstimates(rw, query)
+}
+
+// handleClientEstimates returns the network size estimates of a client in
+// all rounds, oldest first. The client "all" returns the estimates of the
+// networks as a whole.
+func (a *Api) handleClientEstimates(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "max-age=600")
+
+	query := `
+		SELECT` + estimatesColumns + `
+		FROM estimates
+		WHERE client = ?
+		ORDER BY round, network_id, method
+	`
+	a.serveEstimates(rw, query, mux.Vars(r)["client"])
+}
+
+func (a *Api) serveEstimates(rw http.ResponseWriter, query string, args ...interface{}) {
+	var (
+		res estimatesResult
+		key = "e" + toQuery(query, args)
+		err error
+	)
+	if cached, ok := a.cache.Get(key); ok {
+		res.Estimates = cached.([]sizeEstimate)
+	} else {
+		res.Estimates, err = estimatesQuery(a.db, query, args...)
+		if err != nil {
+			log.Error("Failure in the query", "err", err)
+		}
+		a.cache.Add(key, res.Estimates)
+	}
+	json.NewEncoder(rw).Encode(res)
+}
+
+func estimatesQuery(db *sql.DB, query string, args ...interface{}) ([]sizeEstimate, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var estimates []sizeEstimate
+	for rows.Next() {
+		var e sizeEstimate
+		err := rows.Scan(&e.Round, &e.Method, &e.Client, &e.NetworkID, &e.First, &e.Second, &e.Both, &e.Size, &e.Lower, &e.Upper)
+		if err != nil {
+			return nil, err
+		}
+		estimates = append(estimates, e)
+	}
+	return estimates, rows.Err()
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/gorilla/mux"
+)
+
+type failure struct {
+	Name  string `json:"name"`
+	Phase string `json:"phase"`
+	Kind  string `json:"kind"`
+	// Reason is the disconnect reason, if the node disconnected.
+	Reason string `json:"reason,omitempty"`
+	Count  int    `json:"count"`
+}
+
+type failuresResult struct {
+	Failures []failure `json:"failures"`
+}
+
+// handleFailures returns why dials of the nodes failed in the latest crawl
+// round, by client and reason. Only the columns of the failures table can be
+// filtered on.
+func (a *Api) handleFailures(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "max-age=600")
+
+	where, whereArgs, err := addFilterArgs(mux.Vars(r))
+	if err != nil {
+		log.Error("Failure when adding filter to the query", "err", err)
+		return
+	}
+	if whereArgs != nil {
+		where = fmt.Sprintf("AND (%v)", where)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			name,
+			failure_phase,
+			failure_kind,
+			disc_reason,
+			COUNT(*) as Count
+		FROM failures
+		WHERE round = (SELECT MAX(round) FROM failures) %v
+		GROUP BY name, failure_phase, failure_kind, disc_reason
+		ORDER BY Count DESC
+	`, where)
+
+	var res failuresResult
+	if cached, ok := a.cache.Get("f" + toQuery(query, whereArgs)); ok {
+		res.Failures = cached.([]failure)
+	} else {
+		res.Failures, err = failureQuery(a.db, query, whereArgs...)
+		if err != nil {
+			log.Error("Failure in the query", "err", err)
+		}
+		a.cache.Add("f"+toQuery(query, whereArgs), res.Failures)
+	}
+	json.NewEncoder(rw).Encode(res)
+}
+
+func failureQuery(db *sql.DB, query string, args ...interface{}) ([]failure, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var failures []failure
+	for rows.Next() {
+		var (
+			f      failure
+			reason sql.NullInt64
+		)
+		if err := rows.Scan(&f.Name, &f.Phase, &f.Kind, &reason, &f.Count); err != nil {
+			return nil, err
+		}
+		if reason.Valid {
+			f.Reason = p2p.DiscReason(reason.Int64).String()
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
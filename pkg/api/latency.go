@@ -0,0 +1,173 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gorilla/mux"
+)
+
+// latencyGroups are the columns the latencies can be grouped by.
+var latencyGroups = map[string]string{
+	"client":  "name",
+	"country": "country_name",
+	"asn":     "'AS' || asn || ' ' || COALESCE(asn_org, '')",
+}
+
+// latencyPhases are the phases of a crawl, and their latency columns.
+var latencyPhases = []struct{ name, column string }{
+	{"enr", "enr_latency"},
+	{"connect", "connect_latency"},
+	{"handshake", "handshake_latency"},
+	{"hello", "hello_latency"},
+	{"status", "status_latency"},
+}
+
+// latencyStats is the distribution of the latency of a phase, in milliseconds.
+type latencyStats struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean"`
+	P50   int64   `json:"p50"`
+	P90   int64   `json:"p90"`
+	P99   int64   `json:"p99"`
+}
+
+type latencyGroup struct {
+	Name   string                  `json:"name"`
+	Phases map[string]latencyStats `json:"phases"`
+}
+
+type latencyResult struct {
+	Groups []latencyGroup `json:"groups"`
+}
+
+// handleLatency returns the latency distributions of the crawl phases,
+// grouped by client, country or ASN.
+func (a *Api) handleLatency(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "max-age=600")
+
+	vars := mux.Vars(r)
+	group, ok := latencyGroups[vars["group"]]
+	if !ok {
+		http.Error(rw, fmt.Sprintf("unknown group %q", vars["group"]), http.StatusBadRequest)
+		return
+	}
+
+	where, whereArgs, err := addFilterArgs(vars)
+	if err != nil {
+		log.Error("Failure when adding filter to the query", "err", err)
+		return
+	}
+	if whereArgs != nil {
+		where = fmt.Sprintf("AND (%v)", where)
+	}
+
+	columns := ""
+	for _, phase := range latencyPhases {
+		columns += ", " + phase.column
+	}
+	query := fmt.Sprintf(`
+		SELECT %v as Name %v
+		FROM nodes
+		WHERE (enr_latency IS NOT NULL OR connect_latency IS NOT NULL) %v
+	`, group, columns, where)
+
+	var res latencyResult
+	if cached, ok := a.cache.Get("la" + toQuery(query, whereArgs)); ok {
+		res.Groups = cached.([]latencyGroup)
+	} else {
+		res.Groups, err = latencyQuery(a.db, query, whereArgs...)
+		if err != nil {
+			log.Error("Failure in the query", "err", err)
+		}
+		a.cache.Add("la"+toQuery(query, whereArgs), res.Groups)
+	}
+	json.NewEncoder(rw).Encode(res)
+}
+
+func latencyQuery(db *sql.DB, query string, args ...interface{}) ([]latencyGroup, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Latencies by group and phase.
+	latencies := make(map[string][][]int64)
+	for rows.Next() {
+		var (
+			name   sql.NullString
+			values = make([]sql.NullInt64, len(latencyPhases))
+			dest   = []interface{}{&name}
+		)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		phases, ok := latencies[name.String]
+		if !ok {
+			phases = make([][]int64, len(latencyPhases))
+			latencies[name.String] = phases
+		}
+		for i, v := range values {
+			if v.Valid {
+				phases[i] = append(phases[i], v.Int64)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	groups := make([]latencyGroup, 0, len(latencies))
+	for name, phases := range latencies {
+		g := latencyGroup{Name: name, Phases: make(map[string]latencyStats)}
+		for i, values := range phases {
+			if len(values) > 0 {
+				g.Phases[latencyPhases[i].name] = newLatencyStats(values)
+			}
+		}
+		groups = append(groups, g)
+	}
+	// Largest groups first, like the other queries.
+	sort.Slice(groups, func(i, j int) bool {
+		ci, cj := groups[i].Phases["connect"].Count, groups[j].Phases["connect"].Count
+		if ci != cj {
+			return ci > cj
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups, nil
+}
+
+func newLatencyStats(values []int64) latencyStats {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	var sum int64
+	for _, v := range values {
+		sum += v
+	}
+	return latencyStats{
+		Count: len(values),
+		Mean:  float64(sum) / float64(len(values)),
+		P50:   percentile(values, 50),
+		P90:   percentile(values, 90),
+		P99:   percentile(values, 99),
+	}
+}
+
+// percentile returns the nearest-rank percentile of the sorted values.
+func percentile(sorted []int64, p int) int64 {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -2,6 +2,8 @@ package apidb
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"sort"
 	"strings"
 	"time"
@@ -28,16 +30,193 @@ func CreateDB(db *sql.DB) error {
 			language_version    TEXT,
 			last_crawled        DATETIME,
 			country_name        TEXT,
+			ip_family           TEXT,
+			earliest_block      NUMBER,
+			latest_block        NUMBER,
+			block_height        NUMBER,
+			head_time           NUMBER,
+			fork_compat         TEXT,
+			snap_answered       NUMBER,
+			snap_latency        NUMBER,
+			snap_response_size  NUMBER,
+			failure_phase       TEXT,
+			failure_kind        TEXT,
+			disc_reason         NUMBER,
+			asn                 NUMBER,
+			asn_org             TEXT,
+			enr_latency         NUMBER,
+			connect_latency     NUMBER,
+			handshake_latency   NUMBER,
+			hello_latency       NUMBER,
+			status_latency      NUMBER,
+			dial_attempts       NUMBER,
+			disc_protocols      TEXT,
+			discv4_alive        NUMBER,
+			discv5_alive        NUMBER,
+			eip868              NUMBER,
+			enrless             NUMBER,
+			client_source       TEXT,
+			chain               TEXT,
+			fork_stage          TEXT,
 
 			PRIMARY KEY (ID)
 		);
 
 		DELETE FROM nodes;
-	`
+	` + createEstimatesTable + createENRAttributesTable + createChainCountsTable + createFailuresTable
 	_, err := db.Exec(sqlStmt)
 	return err
 }
 
+// createEstimatesTable creates the table of the network size estimates of
+// every crawl round, which was added after the nodes table.
+const createEstimatesTable = `
+	CREATE TABLE IF NOT EXISTS estimates (
+		round       TEXT NOT NULL,
+		method      TEXT NOT NULL,
+		client      TEXT NOT NULL,
+		network_id  NUMBER NOT NULL,
+		first       NUMBER,
+		second      NUMBER,
+		both        NUMBER,
+		size        REAL,
+		lower       REAL,
+		upper       REAL,
+
+		PRIMARY KEY (round, method, client, network_id)
+	);
+`
+
+// createENRAttributesTable creates the table of the key/value pairs of the
+// latest record of every node, which was added after the nodes table.
+const createENRAttributesTable = `
+	CREATE TABLE IF NOT EXISTS enr_attributes (
+		id          TEXT NOT NULL,
+		seq         NUMBER,
+		key         TEXT NOT NULL,
+		value       TEXT,
+		raw         TEXT,
+		decoded     NUMBER,
+		last_seen   DATETIME,
+
+		PRIMARY KEY (id, key)
+	);
+`
+
+// createChainCountsTable creates the table of the number of nodes of every
+// chain and fork stage in every crawl round, which was added after the nodes
+// table.
+const createChainCountsTable = `
+	CREATE TABLE IF NOT EXISTS chain_counts (
+		round       TEXT NOT NULL,
+		chain       TEXT NOT NULL,
+		fork_stage  TEXT NOT NULL,
+		nodes       NUMBER,
+
+		PRIMARY KEY (round, chain, fork_stage)
+	);
+`
+
+// createFailuresTable creates the table of the failed dials of every crawl
+// round, which was added after the nodes table. Nodes we never learned the
+// client of are only stored here, so they don't count on the dashboard.
+const createFailuresTable = `
+	CREATE TABLE IF NOT EXISTS failures (
+		id             TEXT NOT NULL,
+		round          TEXT NOT NULL,
+		name           TEXT NOT NULL,
+		failure_phase  TEXT,
+		failure_kind   TEXT,
+		disc_reason    NUMBER,
+		ip_family      TEXT,
+		asn            NUMBER,
+		chain          TEXT,
+		last_seen      DATETIME,
+
+		PRIMARY KEY (id, round)
+	);
+`
+
+// addedColumns are the columns which were added to the nodes table after
+// its initial version. UpgradeDB adds them to older databases.
+var addedColumns = []struct{ name, typ string }{
+	{"ip_family", "TEXT"},
+	{"earliest_block", "NUMBER"},
+	{"latest_block", "NUMBER"},
+	{"block_height", "NUMBER"},
+	{"head_time", "NUMBER"},
+	{"fork_compat", "TEXT"},
+	{"snap_answered", "NUMBER"},
+	{"snap_latency", "NUMBER"},
+	{"snap_response_size", "NUMBER"},
+	{"failure_phase", "TEXT"},
+	{"failure_kind", "TEXT"},
+	{"disc_reason", "NUMBER"},
+	{"asn", "NUMBER"},
+	{"asn_org", "TEXT"},
+	{"enr_latency", "NUMBER"},
+	{"connect_latency", "NUMBER"},
+	{"handshake_latency", "NUMBER"},
+	{"hello_latency", "NUMBER"},
+	{"status_latency", "NUMBER"},
+	{"dial_attempts", "NUMBER"},
+	{"disc_protocols", "TEXT"},
+	{"discv4_alive", "NUMBER"},
+	{"discv5_alive", "NUMBER"},
+	{"eip868", "NUMBER"},
+	{"enrless", "NUMBER"},
+	{"client_source", "TEXT"},
+	{"chain", "TEXT"},
+	{"fork_stage", "TEXT"},
+}
+
+// UpgradeDB adds any tables and columns missing in a database created by an
+// older version of the API.
+func UpgradeDB(db *sql.DB) error {
+	if _, err := db.Exec(createEstimatesTable); err != nil {
+		return fmt.Errorf("error creating estimates table: %w", err)
+	}
+	if _, err := db.Exec(createENRAttributesTable); err != nil {
+		return fmt.Errorf("error creating ENR attributes table: %w", err)
+	}
+	if _, err := db.Exec(createChainCountsTable); err != nil {
+		return fmt.Errorf("error creating chain counts table: %w", err)
+	}
+	if _, err := db.Exec(createFailuresTable); err != nil {
+		return fmt.Errorf("error creating failures table: %w", err)
+	}
+
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('nodes')`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existing := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, col := range addedColumns {
+		if existing[col.name] {
+			continue
+		}
+		log.Info("Adding column to api db", "column", col.name)
+		_, err := db.Exec(fmt.Sprintf("ALTER TABLE nodes ADD COLUMN %s %s", col.name, col.typ))
+		if err != nil {
+			return fmt.Errorf("error adding column %s: %w", col.name, err)
+		}
+	}
+	return nil
+}
+
 func InsertCrawledNodes(db *sql.DB, crawledNodes []crawlerdb.CrawledNode) error {
 	log.Info("Writing nodes to db", "len", len(crawledNodes))
 
@@ -45,6 +224,10 @@ func InsertCrawledNodes(db *sql.DB, crawledNodes []crawlerdb.CrawledNode) error
 	if err != nil {
 		return err
 	}
+	// The identity of a node record doesn't replace the one of a Hello, the
+	// identity columns keep their values then, and the rest of the row is
+	// updated. Rows without a client source are older than the column, and
+	// were all identified by the Hello.
 	stmt, err := tx.Prepare(`
 		INSERT INTO nodes(
 			id,
@@ -60,24 +243,80 @@ func InsertCrawledNodes(db *sql.DB, crawledNodes []crawlerdb.CrawledNode) error
 			language_name,
 			language_version,
 			last_crawled,
-			country_name
+			country_name,
+			ip_family,
+			earliest_block,
+			latest_block,
+			block_height,
+			head_time,
+			fork_compat,
+			snap_answered,
+			snap_latency,
+			snap_response_size,
+			failure_phase,
+			failure_kind,
+			disc_reason,
+			asn,
+			asn_org,
+			enr_latency,
+			connect_latency,
+			handshake_latency,
+			hello_latency,
+			status_latency,
+			dial_attempts,
+			disc_protocols,
+			discv4_alive,
+			discv5_alive,
+			eip868,
+			enrless,
+			client_source,
+			chain,
+			fork_stage
 		)
-		VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
 		ON CONFLICT(id) DO UPDATE
 		SET
-			name = excluded.name,
-			version_major = excluded.version_major,
-			version_minor = excluded.version_minor,
-			version_patch = excluded.version_patch,
-			version_tag = excluded.version_tag,
-			version_build = excluded.version_build,
-			version_date = excluded.version_date,
-			os_name = excluded.os_name,
-			os_architecture = excluded.os_architecture,
-			language_name = excluded.language_name,
-			language_version = excluded.language_version,
+			name = CASE WHEN COALESCE(client_source, 'hello') = 'hello' AND excluded.client_source = 'enr' THEN name ELSE excluded.name END,
+			version_major = CASE WHEN COALESCE(client_source, 'hello') = 'hello' AND excluded.client_source = 'enr' THEN version_major ELSE excluded.version_major END,
+			version_minor = CASE WHEN COALESCE(client_source, 'hello') = 'hello' AND excluded.client_source = 'enr' THEN version_minor ELSE excluded.version_minor END,
+			version_patch = CASE WHEN COALESCE(client_source, 'hello') = 'hello' AND excluded.client_source = 'enr' THEN version_patch ELSE excluded.version_patch END,
+			version_tag = CASE WHEN COALESCE(client_source, 'hello') = 'hello' AND excluded.client_source = 'enr' THEN version_tag ELSE excluded.version_tag END,
+			version_build = CASE WHEN COALESCE(client_source, 'hello') = 'hello' AND excluded.client_source = 'enr' THEN version_build ELSE excluded.version_build END,
+			version_date = CASE WHEN COALESCE(client_source, 'hello') = 'hello' AND excluded.client_source = 'enr' THEN version_date ELSE excluded.version_date END,
+			os_name = CASE WHEN COALESCE(client_source, 'hello') = 'hello' AND excluded.client_source = 'enr' THEN os_name ELSE excluded.os_name END,
+			os_architecture = CASE WHEN COALESCE(client_source, 'hello') = 'hello' AND excluded.client_source = 'enr' THEN os_architecture ELSE excluded.os_architecture END,
+			language_name = CASE WHEN COALESCE(client_source, 'hello') = 'hello' AND excluded.client_source = 'enr' THEN language_name ELSE excluded.language_name END,
+			language_version = CASE WHEN COALESCE(client_source, 'hello') = 'hello' AND excluded.client_source = 'enr' THEN language_version ELSE excluded.language_version END,
 			last_crawled = excluded.last_crawled,
-			country_name = excluded.country_name
+			country_name = excluded.country_name,
+			ip_family = excluded.ip_family,
+			earliest_block = excluded.earliest_block,
+			latest_block = excluded.latest_block,
+			block_height = excluded.block_height,
+			head_time = excluded.head_time,
+			fork_compat = excluded.fork_compat,
+			snap_answered = excluded.snap_answered,
+			snap_latency = excluded.snap_latency,
+			snap_response_size = excluded.snap_response_size,
+			failure_phase = excluded.failure_phase,
+			failure_kind = excluded.failure_kind,
+			disc_reason = excluded.disc_reason,
+			asn = excluded.asn,
+			asn_org = excluded.asn_org,
+			enr_latency = excluded.enr_latency,
+			connect_latency = excluded.connect_latency,
+			handshake_latency = excluded.handshake_latency,
+			hello_latency = excluded.hello_latency,
+			status_latency = excluded.status_latency,
+			dial_attempts = excluded.dial_attempts,
+			disc_protocols = excluded.disc_protocols,
+			discv4_alive = excluded.discv4_alive,
+			discv5_alive = excluded.discv5_alive,
+			eip868 = excluded.eip868,
+			enrless = excluded.enrless,
+			client_source = CASE WHEN COALESCE(client_source, 'hello') = 'hello' AND excluded.client_source = 'enr' THEN client_source ELSE excluded.client_source END,
+			chain = excluded.chain,
+			fork_stage = excluded.fork_stage
 		WHERE
 			name = excluded.name
 			OR excluded.name != "unknown"
@@ -86,6 +325,40 @@ func InsertCrawledNodes(db *sql.DB, crawledNodes []crawlerdb.CrawledNode) error
 		return err
 	}
 
+	// Nodes which failed before the hello exchange have no client name, only
+	// the failure of nodes known from earlier crawls is recorded.
+	failureStmt, err := tx.Prepare(`
+		UPDATE nodes
+		SET
+			failure_phase = ?,
+			failure_kind = ?,
+			disc_reason = ?
+		WHERE id = ?
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Every failed dial is recorded for its round, under the client name of
+	// the node if it is known, and as unknown otherwise.
+	roundFailureStmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO failures (
+			id,
+			round,
+			name,
+			failure_phase,
+			failure_kind,
+			disc_reason,
+			ip_family,
+			asn,
+			chain,
+			last_seen
+		) VALUES (?, ?, COALESCE((SELECT name FROM nodes WHERE id = ?), ?), ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+
 	// It's possible for us to have the same node scraped multiple times, so
 	// we want to make sure when we are upserting, we get the most recent
 	// scrape upserted last.
@@ -94,7 +367,7 @@ func InsertCrawledNodes(db *sql.DB, crawledNodes []crawlerdb.CrawledNode) error
 	})
 
 	for _, node := range crawledNodes {
-		parsed := vparser.ParseVersionString(node.ClientType)
+		parsed, source := clientIdentity(node)
 		if parsed != nil {
 			_, err = stmt.Exec(
 				node.ID,
@@ -111,6 +384,62 @@ func InsertCrawledNodes(db *sql.DB, crawledNodes []crawlerdb.CrawledNode) error
 				parsed.Language.Version,
 				time.Now(),
 				node.Country,
+				node.IPFamily,
+				node.EarliestBlock,
+				node.LatestBlock,
+				node.Blockheight,
+				node.HeadTime,
+				node.ForkCompat,
+				node.SnapAnswered,
+				node.SnapLatency,
+				node.SnapResponseSize,
+				node.FailurePhase,
+				node.FailureKind,
+				node.DiscReason,
+				node.ASN,
+				node.ASNOrg,
+				node.ENRLatency,
+				node.ConnectLatency,
+				node.HandshakeLatency,
+				node.HelloLatency,
+				node.StatusLatency,
+				node.DialAttempts,
+				node.DiscProtocols,
+				node.DiscV4Alive,
+				node.DiscV5Alive,
+				node.EIP868,
+				node.ENRLess,
+				source,
+				node.Chain,
+				node.ForkStage,
+			)
+			if err != nil {
+				panic(err)
+			}
+		} else if node.FailureKind != "" {
+			_, err = failureStmt.Exec(
+				node.FailurePhase,
+				node.FailureKind,
+				node.DiscReason,
+				node.ID,
+			)
+			if err != nil {
+				panic(err)
+			}
+		}
+		if node.FailureKind != "" {
+			_, err = roundFailureStmt.Exec(
+				node.ID,
+				node.Now,
+				node.ID,
+				UnknownClient,
+				node.FailurePhase,
+				node.FailureKind,
+				node.DiscReason,
+				node.IPFamily,
+				node.ASN,
+				node.Chain,
+				time.Now(),
 			)
 			if err != nil {
 				panic(err)
@@ -120,22 +449,295 @@ func InsertCrawledNodes(db *sql.DB, crawledNodes []crawlerdb.CrawledNode) error
 	return tx.Commit()
 }
 
-func DropOldNodes(db *sql.DB, minTimePassed time.Duration) error {
-	log.Info("Dropping nodes", "older than", minTimePassed)
-	oldest := time.Now().Add(-minTimePassed)
+// UnknownClient is the name the failures of nodes whose client we never
+// learned are recorded under.
+const UnknownClient = "unknown"
+
+// The sources of the client identity of the nodes.
+const (
+	ClientSourceHello = "hello"
+	ClientSourceENR   = "enr"
+)
+
+// clientIdentity returns the client identity of the node and where it comes
+// from. The Hello is preferred, the EIP-7636 entry of the record is used for
+// nodes we couldn't get it from, including those which had too many peers
+// or which only announce a consensus client.
+func clientIdentity(node crawlerdb.CrawledNode) (*vparser.ParsedInfo, string) {
+	parsed := vparser.ParseVersionString(node.ClientType)
+	if parsed != nil && parsed.Name != "tmp" && parsed.Name != "eth2" {
+		return parsed, ClientSourceHello
+	}
+	if node.ENRClient != "" {
+		if enr := vparser.ParseENRClient(node.ENRClient); enr != nil {
+			return enr, ClientSourceENR
+		}
+	}
+	if parsed != nil {
+		return parsed, ""
+	}
+	return nil, ""
+}
+
+// InsertEstimates stores the size estimates of the crawl rounds. Estimates
+// which were already stored are replaced.
+func InsertEstimates(db *sql.DB, estimates []crawlerdb.RoundEstimate) error {
+	log.Info("Writing estimates to db", "len", len(estimates))
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO estimates(
+			round,
+			method,
+			client,
+			network_id,
+			first,
+			second,
+			both,
+			size,
+			lower,
+			upper
+		)
+		VALUES (?,?,?,?,?,?,?,?,?,?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, e := range estimates {
+		_, err = stmt.Exec(
+			e.Round,
+			e.Method,
+			e.Client,
+			e.NetworkID,
+			e.First,
+			e.Second,
+			e.Both,
+			e.Size,
+			e.Lower,
+			e.Upper,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// InsertChainCounts stores the number of nodes of every chain and fork stage
+// of the crawl rounds. Counts which were already stored are replaced.
+func InsertChainCounts(db *sql.DB, counts []crawlerdb.ChainCount) error {
+	log.Info("Writing chain counts to db", "len", len(counts))
+
 	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
-	stmt, err := tx.Prepare(`DELETE FROM nodes WHERE last_crawled < ?`)
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO chain_counts(
+			round,
+			chain,
+			fork_stage,
+			nodes
+		)
+		VALUES (?,?,?,?)
+	`)
 	if err != nil {
 		return err
 	}
-	res, err := stmt.Exec(oldest)
+	defer stmt.Close()
+
+	for _, c := range counts {
+		if _, err := stmt.Exec(c.Round, c.Chain, c.Stage, c.Nodes); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// InsertENRAttrs stores the key/value pairs of the node records. The pairs
+// of a node are replaced by those of a record with the same or a higher
+// sequence number, so keys removed from the record are removed here too.
+func InsertENRAttrs(db *sql.DB, attrs []crawlerdb.ENRAttr) error {
+	log.Info("Writing ENR attributes to db", "len", len(attrs))
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deleteStmt, err := tx.Prepare(`DELETE FROM enr_attributes WHERE id = ? AND seq <= ?`)
+	if err != nil {
+		return err
+	}
+	defer deleteStmt.Close()
+	stmt, err := tx.Prepare(`
+		INSERT INTO enr_attributes(
+			id,
+			seq,
+			key,
+			value,
+			raw,
+			decoded,
+			last_seen
+		)
+		VALUES (?,?,?,?,?,?,?)
+		ON CONFLICT(id, key) DO UPDATE
+		SET
+			seq = excluded.seq,
+			value = excluded.value,
+			raw = excluded.raw,
+			decoded = excluded.decoded,
+			last_seen = excluded.last_seen
+		WHERE
+			excluded.seq >= seq
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	// Like the nodes, the records of later crawls are stored last.
+	sort.SliceStable(attrs, func(i, j int) bool {
+		return strings.Compare(attrs[i].Now, attrs[j].Now) < 0
+	})
+
+	now := time.Now()
+	var prevID, prevNow string
+	for _, a := range attrs {
+		if a.ID != prevID || a.Now != prevNow {
+			if _, err := deleteStmt.Exec(a.ID, a.Seq); err != nil {
+				return err
+			}
+			prevID, prevNow = a.ID, a.Now
+		}
+		_, err = stmt.Exec(a.ID, a.Seq, a.Key, a.Value, a.Raw, a.Decoded, now)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Node is the row of a node in the API DB, as shown by the enr lookup
+// command.
+type Node struct {
+	ID           string
+	Name         string
+	Version      string
+	OS           string
+	ClientSource string
+	LastCrawled  string
+	Country      string
+	Chain        string
+	ForkStage    string
+	FailurePhase string
+	FailureKind  string
+}
+
+// ReadNode returns the row of the node, or nil if the API doesn't know it.
+func ReadNode(db *sql.DB, id string) (*Node, error) {
+	var n Node
+	err := db.QueryRow(`
+		SELECT
+			id,
+			COALESCE(name, ''),
+			COALESCE(version_major || '.' || version_minor || '.' || version_patch, ''),
+			COALESCE(os_name, ''),
+			COALESCE(client_source, ''),
+			COALESCE(last_crawled, ''),
+			COALESCE(country_name, ''),
+			COALESCE(chain, ''),
+			COALESCE(fork_stage, ''),
+			COALESCE(failure_phase, ''),
+			COALESCE(failure_kind, '')
+		FROM nodes
+		WHERE id = ?
+	`, id).Scan(
+		&n.ID,
+		&n.Name,
+		&n.Version,
+		&n.OS,
+		&n.ClientSource,
+		&n.LastCrawled,
+		&n.Country,
+		&n.Chain,
+		&n.ForkStage,
+		&n.FailurePhase,
+		&n.FailureKind,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// ReadENRAttrs returns the key/value pairs of the latest record of the node.
+// Now is the time the pairs were last seen.
+func ReadENRAttrs(db *sql.DB, id string) ([]crawlerdb.ENRAttr, error) {
+	rows, err := db.Query(`
+		SELECT
+			id,
+			COALESCE(last_seen, ''),
+			seq,
+			key,
+			COALESCE(value, ''),
+			COALESCE(raw, ''),
+			COALESCE(decoded, 0)
+		FROM enr_attributes
+		WHERE id = ?
+		ORDER BY key
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attrs []crawlerdb.ENRAttr
+	for rows.Next() {
+		var a crawlerdb.ENRAttr
+		if err := rows.Scan(&a.ID, &a.Now, &a.Seq, &a.Key, &a.Value, &a.Raw, &a.Decoded); err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, a)
+	}
+	return attrs, rows.Err()
+}
+
+func DropOldNodes(db *sql.DB, minTimePassed time.Duration) error {
+	log.Info("Dropping nodes", "older than", minTimePassed)
+	oldest := time.Now().Add(-minTimePassed)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	// The transaction must not outlive a failure, the daemon keeps running.
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM nodes WHERE last_crawled < ?`, oldest)
 	if err != nil {
 		return err
 	}
 	affected, _ := res.RowsAffected()
 	log.Info("Nodes drop", "affected", affected)
+
+	if _, err := tx.Exec(`DELETE FROM enr_attributes WHERE last_seen < ?`, oldest); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM failures WHERE last_seen < ?`, oldest); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
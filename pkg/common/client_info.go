@@ -2,19 +2,73 @@ package common
 
 import (
 	"math/big"
+	"net/netip"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/p2p"
 )
 
+// Fork ID compatibility of a node with the crawled network.
+const (
+	// ForkCompatible nodes are on our chain, and agree on the next fork.
+	ForkCompatible = "compatible"
+	// ForkStale nodes are on our chain, but miss an upcoming fork.
+	ForkStale = "stale"
+	// ForkFuture nodes have passed or scheduled forks we do not know of.
+	ForkFuture = "future"
+	// ForkIncompatible nodes are on a different chain.
+	ForkIncompatible = "incompatible"
+)
+
 type ClientInfo struct {
 	ClientType      string
 	SoftwareVersion uint64
 	Capabilities    []p2p.Cap
 	NetworkID       uint64
+	Genesis         common.Hash
 	ForkID          forkid.ID
-	Blockheight     string
+	// ForkCompat is the compatibility of ForkID with the crawled network,
+	// one of the Fork* constants. It is empty if the node sent no status.
+	ForkCompat  string
+	Blockheight string
+	// HeadTime is the timestamp of the node's head block.
+	HeadTime        uint64
 	TotalDifficulty *big.Int
 	HeadHash        common.Hash
+	// EarliestBlock and LatestBlock are the range of blocks an eth/69 node
+	// advertises to serve. Both are zero for earlier protocol versions.
+	EarliestBlock uint64
+	LatestBlock   uint64
+	// DialAddr is the address the RLPx connection was made to.
+	DialAddr netip.AddrPort
+	// Snap is the result of the snap probe, nil if the node was not probed.
+	Snap *SnapProbe `json:",omitempty"`
+	// Timings are the durations of the phases of the dial which got the
+	// info.
+	Timings Timings
+	// DialAttempts is the number of dials it took to get the client info,
+	// more than one if the node rejected us for having too many peers.
+	DialAttempts int
+}
+
+// Timings are the durations of the phases of getting the client info of a
+// node. Phases which were not reached are zero.
+type Timings struct {
+	RequestENR time.Duration
+	Connect    time.Duration
+	Handshake  time.Duration
+	Hello      time.Duration
+	Status     time.Duration
+}
+
+// SnapProbe is the result of requesting snap data from a node.
+type SnapProbe struct {
+	// Answered is set if the node responded to the request.
+	Answered bool
+	// Latency is the time until the response arrived.
+	Latency time.Duration
+	// ResponseSize is the size of the response payload in bytes.
+	ResponseSize uint64
 }
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Discovery protocols.
+const (
+	DiscV4 = "discv4"
+	DiscV5 = "discv5"
+)
+
+// DiscoveryLiveness is what one discovery protocol knows about a node.
+type DiscoveryLiveness struct {
+	// Found reports whether the lookups of the protocol returned the node,
+	// as opposed to it only being revalidated from an earlier round.
+	Found bool `json:"found,omitempty"`
+	// LastCheck is the time of the last ENR request, LastResponse the time
+	// of the last answer to one.
+	LastCheck    time.Time `json:"lastCheck,omitempty"`
+	LastResponse time.Time `json:"lastResponse,omitempty"`
+}
+
+// Alive reports whether the node answered the last ENR request.
+func (d DiscoveryLiveness) Alive() bool {
+	return !d.LastResponse.IsZero() && !d.LastResponse.Before(d.LastCheck)
+}
+
+// SetDiscovery sets the liveness of the node in the given protocol. The map
+// is copied, as copies of the node share it.
+func (n *NodeJSON) SetDiscovery(protocol string, d DiscoveryLiveness) {
+	discovery := make(map[string]DiscoveryLiveness, len(n.Discovery)+1)
+	for p, v := range n.Discovery {
+		discovery[p] = v
+	}
+	discovery[protocol] = d
+	n.Discovery = discovery
+}
+
+// DiscoveryProtocols returns the sorted, comma-separated protocols whose
+// lookups returned the node.
+func (n NodeJSON) DiscoveryProtocols() string {
+	var found []string
+	for p, d := range n.Discovery {
+		if d.Found {
+			found = append(found, p)
+		}
+	}
+	sort.Strings(found)
+	return strings.Join(found, ",")
+}
+
+// SupportsEIP868 reports whether the node answers discv4 ENR requests. It is
+// false if the node was never checked with discv4, ok reports that.
+func (n NodeJSON) SupportsEIP868() (supported, ok bool) {
+	d, ok := n.Discovery[DiscV4]
+	if !ok || d.LastCheck.IsZero() {
+		return false, false
+	}
+	return !d.LastResponse.IsZero(), true
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// Phases of getting the client info of a node, in order.
+const (
+	FailPhaseConnect   = "connect"
+	FailPhaseHandshake = "handshake"
+	FailPhaseHello     = "hello"
+	FailPhaseStatus    = "status"
+)
+
+// Kinds of failures.
+const (
+	// FailKindNoEndpoint means the node record has no TCP endpoint.
+	FailKindNoEndpoint = "no-endpoint"
+	FailKindTimeout    = "timeout"
+	FailKindRefused    = "refused"
+	// FailKindNetwork is any other error of the network connection.
+	FailKindNetwork = "network"
+	// FailKindClosed means the node closed the connection without a
+	// disconnect message.
+	FailKindClosed = "closed"
+	// FailKindDisconnect means the node sent a disconnect message, the
+	// reason is recorded in DialFailure.Reason.
+	FailKindDisconnect = "disconnect"
+	FailKindDecode     = "decode"
+	// FailKindProtocol means the node sent an unexpected message.
+	FailKindProtocol = "protocol"
+	FailKindOther    = "other"
+)
+
+// DialFailure describes why getting the client info of a node failed.
+type DialFailure struct {
+	Phase string
+	Kind  string
+	// Reason is the disconnect reason sent by the node. It is only valid if
+	// Kind is FailKindDisconnect.
+	Reason p2p.DiscReason
+}
+
+func (f DialFailure) String() string {
+	if f.Kind == FailKindDisconnect {
+		return fmt.Sprintf("%s: %s: %v", f.Phase, f.Kind, f.Reason)
+	}
+	return fmt.Sprintf("%s: %s", f.Phase, f.Kind)
+}
+
+// TooManyPeers reports whether the node refused the connection because it
+// has too many peers.
+func (f DialFailure) TooManyPeers() bool {
+	return f.Kind == FailKindDisconnect && f.Reason == p2p.DiscTooManyPeers
+}
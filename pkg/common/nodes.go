@@ -41,15 +41,79 @@ type NodeJSON struct {
 	// The score tracks how many liveness checks were performed. It is incremented by one
 	// every time the node passes a check, and halved every time it doesn't.
 	Score int `json:"score,omitempty"`
+	// Uptime is the ratio of successful liveness checks, if the uptime
+	// scoring policy is used.
+	Uptime float64 `json:"uptime,omitempty"`
+	// ScoreHistory are the latest changes of the score, oldest first.
+	ScoreHistory []ScoreEvent `json:"scoreHistory,omitempty"`
 	// These two track the time of last successful contact.
 	FirstResponse time.Time `json:"firstResponse,omitempty"`
 	LastResponse  time.Time `json:"lastResponse,omitempty"`
 	// This one tracks the time of our last attempt to contact the node.
 	LastCheck time.Time `json:"lastCheck,omitempty"`
 
+	// Discovery is the liveness of the node in every discovery protocol which
+	// found or checked it.
+	Discovery map[string]DiscoveryLiveness `json:"discovery,omitempty"`
+
 	Info *ClientInfo `json:"clientInfo,omitempty"`
+	// LastClientInfo is the time we last got the client info of the node.
+	LastClientInfo time.Time `json:"lastClientInfo,omitempty"`
 
 	TooManyPeers bool `json:"tooManyPeers,omitempty"`
+	// ENRLess marks discv4 nodes which don't answer ENR requests (EIP-868).
+	// They were dialed with the endpoint they were discovered with, and N
+	// is not a signed record.
+	ENRLess bool `json:"enrless,omitempty"`
+	// BusyAttempts counts the consecutive dials rejected because the node
+	// has too many peers. NextDial is the earliest time to dial it again.
+	BusyAttempts int       `json:"busyAttempts,omitempty"`
+	NextDial     time.Time `json:"nextDial,omitempty"`
+	// Failure is why the last attempt to get the client info failed, nil
+	// if it succeeded.
+	Failure *DialFailure `json:"failure,omitempty"`
+	// Timings are the durations of the phases of the last dial, whether it
+	// succeeded or not. Nil if the node was never dialed.
+	Timings *Timings `json:"timings,omitempty"`
+}
+
+// maxScoreHistory is the number of score changes kept for every node.
+const maxScoreHistory = 16
+
+// Checks which change the score of a node.
+const (
+	CheckENR  = "enr"
+	CheckDial = "dial"
+)
+
+// ScoreEvent is the score of a node after one of its checks.
+type ScoreEvent struct {
+	Time  time.Time `json:"time"`
+	Check string    `json:"check"`
+	OK    bool      `json:"ok"`
+	Score int       `json:"score"`
+}
+
+func (e ScoreEvent) String() string {
+	result := "ok"
+	if !e.OK {
+		result = "failed"
+	}
+	return fmt.Sprintf("%s %s %s: %d", e.Time.Format(time.RFC3339), e.Check, result, e.Score)
+}
+
+// RecordScore appends the current score to the history of the node, after
+// the given check. The history is copied, as copies of the node share it.
+func (n *NodeJSON) RecordScore(check string, ok bool) {
+	start := max(0, len(n.ScoreHistory)+1-maxScoreHistory)
+	history := make([]ScoreEvent, 0, len(n.ScoreHistory)+1-start)
+	history = append(history, n.ScoreHistory[start:]...)
+	n.ScoreHistory = append(history, ScoreEvent{
+		Time:  time.Now().UTC().Truncate(time.Second),
+		Check: check,
+		OK:    ok,
+		Score: n.Score,
+	})
 }
 
 func LoadNodesJSON(file string) NodeSet {
@@ -20,8 +20,13 @@ import (
 	"crypto/ecdsa"
 	"encoding/hex"
 	"fmt"
+	"net/netip"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/rlpx"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -89,6 +94,33 @@ type Status eth.StatusPacket
 func (msg Status) Code() int     { return 16 }
 func (msg Status) ReqID() uint64 { return 0 }
 
+// Status69 is the network packet for the status message for eth/69 and later.
+// It replaces the total difficulty and head of earlier versions with the
+// range of blocks the node can serve.
+type Status69 struct {
+	ProtocolVersion uint32
+	NetworkID       uint64
+	Genesis         common.Hash
+	ForkID          forkid.ID
+	EarliestBlock   uint64
+	LatestBlock     uint64
+	LatestBlockHash common.Hash
+}
+
+func (msg Status69) Code() int     { return 16 }
+func (msg Status69) ReqID() uint64 { return 0 }
+
+// BlockRangeUpdate is sent by eth/69 nodes when the range of blocks they can
+// serve changes.
+type BlockRangeUpdate struct {
+	EarliestBlock   uint64
+	LatestBlock     uint64
+	LatestBlockHash common.Hash
+}
+
+func (msg BlockRangeUpdate) Code() int     { return 33 }
+func (msg BlockRangeUpdate) ReqID() uint64 { return 0 }
+
 // NewBlockHashes is the network packet for the block announcements.
 type NewBlockHashes eth.NewBlockHashesPacket
 
@@ -151,9 +183,47 @@ type PooledTransactions eth.PooledTransactionsPacket
 func (msg PooledTransactions) Code() int     { return 26 }
 func (msg PooledTransactions) ReqID() uint64 { return msg.RequestId }
 
+// snapMessage is implemented by the messages of the snap protocol. Their
+// codes are relative to the end of the eth protocol.
+type snapMessage interface {
+	Message
+	snap()
+}
+
+// GetAccountRange is the snap packet for an account range request.
+type GetAccountRange snap.GetAccountRangePacket
+
+func (msg GetAccountRange) Code() int     { return snap.GetAccountRangeMsg }
+func (msg GetAccountRange) ReqID() uint64 { return msg.ID }
+func (msg GetAccountRange) snap()         {}
+
+// AccountRange is the snap packet for an account range response.
+type AccountRange snap.AccountRangePacket
+
+func (msg AccountRange) Code() int     { return snap.AccountRangeMsg }
+func (msg AccountRange) ReqID() uint64 { return msg.ID }
+func (msg AccountRange) snap()         {}
+
+// GetByteCodes is the snap packet for a contract code request.
+type GetByteCodes snap.GetByteCodesPacket
+
+func (msg GetByteCodes) Code() int     { return snap.GetByteCodesMsg }
+func (msg GetByteCodes) ReqID() uint64 { return msg.ID }
+func (msg GetByteCodes) snap()         {}
+
+// ByteCodes is the snap packet for a contract code response.
+type ByteCodes snap.ByteCodesPacket
+
+func (msg ByteCodes) Code() int     { return snap.ByteCodesMsg }
+func (msg ByteCodes) ReqID() uint64 { return msg.ID }
+func (msg ByteCodes) snap()         {}
+
 // Conn represents an individual connection with a peer
 type Conn struct {
 	*rlpx.Conn
+	addr                       netip.AddrPort
+	connectTime                time.Duration
+	handshakeTime              time.Duration
 	ourKey                     *ecdsa.PrivateKey
 	negotiatedProtoVersion     uint
 	negotiatedSnapProtoVersion uint
@@ -166,7 +236,11 @@ type Conn struct {
 func (c *Conn) Read() Message {
 	code, rawData, _, err := c.Conn.Read()
 	if err != nil {
-		return errorf("could not read from connection: %v", err)
+		return errorf("could not read from connection: %w", err)
+	}
+
+	if c.negotiatedSnapProtoVersion > 0 && code >= c.snapOffset() {
+		return c.readSnap(code-c.snapOffset(), rawData)
 	}
 
 	var msg Message
@@ -185,29 +259,33 @@ func (c *Conn) Read() Message {
 		}
 		msg = new(Disconnect)
 	case (Status{}).Code():
-		msg = new(Status)
+		if c.negotiatedProtoVersion >= 69 {
+			msg = new(Status69)
+		} else {
+			msg = new(Status)
+		}
 	case (GetBlockHeaders{}).Code():
 		ethMsg := new(eth.GetBlockHeadersPacket)
 		if err := rlp.DecodeBytes(rawData, ethMsg); err != nil {
-			return errorf("could not rlp decode message: %v", err)
+			return errorf("%w: %v", errDecode, err)
 		}
 		return (*GetBlockHeaders)(ethMsg)
 	case (BlockHeaders{}).Code():
 		ethMsg := new(eth.BlockHeadersPacket)
 		if err := rlp.DecodeBytes(rawData, ethMsg); err != nil {
-			return errorf("could not rlp decode message: %v", err)
+			return errorf("%w: %v", errDecode, err)
 		}
 		return (*BlockHeaders)(ethMsg)
 	case (GetBlockBodies{}).Code():
 		ethMsg := new(eth.GetBlockBodiesPacket)
 		if err := rlp.DecodeBytes(rawData, ethMsg); err != nil {
-			return errorf("could not rlp decode message: %v", err)
+			return errorf("%w: %v", errDecode, err)
 		}
 		return (*GetBlockBodies)(ethMsg)
 	case (BlockBodies{}).Code():
 		ethMsg := new(eth.BlockBodiesPacket)
 		if err := rlp.DecodeBytes(rawData, ethMsg); err != nil {
-			return errorf("could not rlp decode message: %v", err)
+			return errorf("%w: %v", errDecode, err)
 		}
 		return (*BlockBodies)(ethMsg)
 	case (NewBlock{}).Code():
@@ -223,43 +301,86 @@ func (c *Conn) Read() Message {
 			return ethMsg
 		}
 		msg = new(NewPooledTransactionHashes66)
+	case (BlockRangeUpdate{}).Code():
+		// Before eth/69, this code belongs to the next protocol.
+		if c.negotiatedProtoVersion < 69 {
+			return errorf("%w: %d", errInvalidCode, code)
+		}
+		msg = new(BlockRangeUpdate)
 	case (GetPooledTransactions{}.Code()):
 		ethMsg := new(eth.GetPooledTransactionsPacket)
 		if err := rlp.DecodeBytes(rawData, ethMsg); err != nil {
-			return errorf("could not rlp decode message: %v", err)
+			return errorf("%w: %v", errDecode, err)
 		}
 		return (*GetPooledTransactions)(ethMsg)
 	case (PooledTransactions{}.Code()):
 		ethMsg := new(eth.PooledTransactionsPacket)
 		if err := rlp.DecodeBytes(rawData, ethMsg); err != nil {
-			return errorf("could not rlp decode message: %v", err)
+			return errorf("%w: %v", errDecode, err)
 		}
 		return (*PooledTransactions)(ethMsg)
 	default:
-		msg = errorf("invalid message code: %d", code)
+		msg = errorf("%w: %d", errInvalidCode, code)
 	}
 
 	if msg != nil {
 		if err := rlp.DecodeBytes(rawData, msg); err != nil {
 			fmt.Println(hex.EncodeToString(rawData))
-			return errorf("could not rlp decode message: %v", err)
+			return errorf("%w: %v", errDecode, err)
 		}
 		return msg
 	}
 	return errorf("invalid message: %s", string(rawData))
 }
 
-// Write writes a eth packet to the connection.
+// readSnap decodes a snap packet with the given code relative to the
+// snap offset.
+func (c *Conn) readSnap(code uint64, rawData []byte) Message {
+	var msg Message
+	switch int(code) {
+	case (GetAccountRange{}).Code():
+		msg = new(GetAccountRange)
+	case (AccountRange{}).Code():
+		msg = new(AccountRange)
+	case (GetByteCodes{}).Code():
+		msg = new(GetByteCodes)
+	case (ByteCodes{}).Code():
+		msg = new(ByteCodes)
+	default:
+		return errorf("%w: snap: %d", errInvalidCode, code)
+	}
+
+	if err := rlp.DecodeBytes(rawData, msg); err != nil {
+		return errorf("%w: snap: %v", errDecode, err)
+	}
+	return msg
+}
+
+// snapOffset returns the code of the first snap message, which follows the
+// base protocol and the negotiated eth protocol.
+func (c *Conn) snapOffset() uint64 {
+	const baseProtocolLength = 16
+	if c.negotiatedProtoVersion >= 69 {
+		return baseProtocolLength + 18
+	}
+	return baseProtocolLength + 17
+}
+
+// Write writes a eth or snap packet to the connection.
 func (c *Conn) Write(msg Message) error {
 	payload, err := rlp.EncodeToBytes(msg)
 	if err != nil {
 		return err
 	}
-	_, err = c.Conn.Write(uint64(msg.Code()), payload)
+	code := uint64(msg.Code())
+	if _, ok := msg.(snapMessage); ok {
+		code += c.snapOffset()
+	}
+	_, err = c.Conn.Write(code, payload)
 	return err
 }
 
-// negotiateEthProtocol sets the Conn's eth protocol version
+// negotiateEthProtocol sets the Conn's eth and snap protocol versions
 // to highest advertised capability from peer
 func (c *Conn) negotiateEthProtocol(caps []p2p.Cap) {
 	var highestEthVersion uint
@@ -272,4 +393,16 @@ func (c *Conn) negotiateEthProtocol(caps []p2p.Cap) {
 		}
 	}
 	c.negotiatedProtoVersion = highestEthVersion
+
+	// Snap runs on top of eth, it is only used if eth was negotiated too.
+	var highestSnapVersion uint
+	for _, capability := range caps {
+		if capability.Name != "snap" || highestEthVersion == 0 {
+			continue
+		}
+		if capability.Version > highestSnapVersion && capability.Version <= c.ourHighestSnapProtoVersion {
+			highestSnapVersion = capability.Version
+		}
+	}
+	c.negotiatedSnapProtoVersion = highestSnapVersion
 }
@@ -0,0 +1,103 @@
+package crawler
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/rlpx"
+)
+
+// newTestConns returns the two ends of an RLPx connection over a pipe,
+// both negotiated to the given eth version.
+func newTestConns(t *testing.T, ethVersion uint) (*Conn, *Conn) {
+	t.Helper()
+
+	clientKey, _ := crypto.GenerateKey()
+	serverKey, _ := crypto.GenerateKey()
+	fd1, fd2 := net.Pipe()
+
+	client := &Conn{Conn: rlpx.NewConn(fd1, &serverKey.PublicKey), negotiatedProtoVersion: ethVersion}
+	server := &Conn{Conn: rlpx.NewConn(fd2, nil), negotiatedProtoVersion: ethVersion}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := server.Handshake(serverKey)
+		errc <- err
+	}()
+	if _, err := client.Handshake(clientKey); err != nil {
+		t.Fatal("client handshake failed:", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal("server handshake failed:", err)
+	}
+	return client, server
+}
+
+func TestStatus69(t *testing.T) {
+	client, server := newTestConns(t, 69)
+
+	want := Status69{
+		ProtocolVersion: 69,
+		NetworkID:       1,
+		Genesis:         common.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"),
+		ForkID:          forkid.ID{Hash: [4]byte{0x9f, 0x3d, 0x22, 0x54}},
+		EarliestBlock:   15537394,
+		LatestBlock:     22000000,
+		LatestBlockHash: common.HexToHash("0x01"),
+	}
+	go func() {
+		server.Write(&want)
+		server.Write(&BlockRangeUpdate{EarliestBlock: 15537394, LatestBlock: 22000001})
+	}()
+
+	got, ok := client.Read().(*Status69)
+	if !ok {
+		t.Fatal("expected eth/69 status")
+	}
+	if *got != want {
+		t.Fatalf("wrong status: got %+v, want %+v", got, want)
+	}
+	update, ok := client.Read().(*BlockRangeUpdate)
+	if !ok {
+		t.Fatal("expected block range update")
+	}
+	if update.LatestBlock != 22000001 {
+		t.Fatalf("wrong latest block: %d", update.LatestBlock)
+	}
+}
+
+func TestSnapMessages(t *testing.T) {
+	for _, version := range []uint{68, 69} {
+		client, server := newTestConns(t, version)
+		client.negotiatedSnapProtoVersion = 1
+		server.negotiatedSnapProtoVersion = 1
+
+		go func() {
+			// With snap negotiated, eth/69 messages keep their codes.
+			if version >= 69 {
+				server.Write(&BlockRangeUpdate{LatestBlock: 1})
+			}
+			server.Write(&ByteCodes{ID: 7, Codes: [][]byte{{0x60, 0x00}}})
+		}()
+
+		if version >= 69 {
+			if _, ok := client.Read().(*BlockRangeUpdate); !ok {
+				t.Fatalf("eth/%d: expected block range update", version)
+			}
+		}
+		codes, ok := client.Read().(*ByteCodes)
+		if !ok {
+			t.Fatalf("eth/%d: expected byte codes", version)
+		}
+		if codes.ID != 7 || len(codes.Codes) != 1 {
+			t.Fatalf("eth/%d: wrong byte codes %+v", version, codes)
+		}
+	}
+}
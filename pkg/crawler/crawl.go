@@ -17,60 +17,161 @@
 package crawler
 
 import (
+	"context"
+	"crypto/ecdsa"
 	"database/sql"
-	"strings"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/node-crawler/pkg/common"
 	"github.com/ethereum/node-crawler/pkg/crawlerdb"
+	"github.com/ethereum/node-crawler/pkg/estimate"
+	"github.com/ethereum/node-crawler/pkg/networks"
+	"github.com/ethereum/node-crawler/pkg/peergraph"
 	"github.com/oschwald/geoip2-golang"
 )
 
 type Crawler struct {
+	// Network is the profile of the network to crawl. It must be set.
+	Network *networks.Profile
+
 	// These are probably from flags
-	NetworkID  uint64
-	NodeURL    string
+	NetworkID  uint64 // Overrides the network ID of the profile if set
 	ListenAddr string
 	NodeKey    string
-	Bootnodes  []string
+	Bootnodes  []string // Overrides the bootnodes of the profile if set
 	Timeout    time.Duration
 	Workers    uint64
-	Sepolia    bool
-	Hoodi      bool
+	// DialFallback enables dialing the other address family of nodes
+	// which have both an IPv4 and an IPv6 address.
+	DialFallback bool
+	// DialQueueSize is the capacity of the dial queue. Nodes waiting for
+	// the queue hold up their ENR worker.
+	DialQueueSize int
+	// RetryDelay is the initial delay before dialing a node again which
+	// rejected us because it has too many peers. It doubles with every
+	// attempt, up to RetryMaxDelay. Such nodes are not retried if it is zero.
+	RetryDelay    time.Duration
+	RetryMaxDelay time.Duration
+	// Scoring decides the scores of the nodes, and which are dropped. The
+	// DefaultScoring is used if it is nil.
+	Scoring ScoringPolicy
+	// DialENRLess enables dialing discv4 nodes which don't answer ENR
+	// requests (EIP-868) with the endpoint they were discovered with. They
+	// are kept apart from the other nodes, see common.NodeJSON.ENRLess.
+	DialENRLess bool
+	// ForkFilter decides whether nodes whose record announces the fork ID
+	// of another network are dialed. They are counted in any case.
+	ForkFilter ForkFilter
+	// SnapProbe enables requesting snap data from nodes supporting snap,
+	// to check whether they serve it.
+	SnapProbe bool
+	// RLPxKey is the identity used for RLPx connections. A new key is
+	// generated for every connection if it is nil.
+	RLPxKey *ecdsa.PrivateKey
+	// ClientName and ListenPort are announced in the Hello message, so node
+	// operators can identify the crawler.
+	ClientName string
+	ListenPort uint64
 
 	NodeDB *enode.DB
+
+	// Status provides the head announced in our status messages. The
+	// genesis block is announced if it is nil.
+	Status StatusProvider
+
+	// DeepCrawl queries the routing tables of the live nodes to build the
+	// peer graph of every round, which is written to GraphDir in
+	// GraphFormat if set.
+	DeepCrawl   bool
+	GraphDir    string
+	GraphFormat string
+
+	// Estimator estimates the size of the network from consecutive rounds.
+	// It must be shared by all rounds, or be nil to skip these estimates.
+	Estimator *estimate.Rounds
+
+	// Chains labels the nodes with the known chain they are on. Nodes are
+	// not labeled if it is nil.
+	Chains *networks.Registry
 }
 
 type crawler struct {
 	output common.NodeSet
+	// enrless are the nodes which don't answer ENR requests, if they are
+	// dialed.
+	enrless     common.NodeSet
+	dialENRLess bool
 
-	genesis   *core.Genesis
-	networkID uint64
-	nodeURL   string
+	status *chainStatus
 
 	disc resolver
-
+	// clientInfo dials the node and fetches its client info. It is
+	// getClientInfo by default, and replaced in tests.
+	clientInfo func(*enode.Node) (*common.ClientInfo, error)
+	// forks classifies the fork IDs of the nodes. Fork IDs are not
+	// classified if it is nil.
+	forks      *forkChecker
+	forkFilter ForkFilter
+	scoring    ScoringPolicy
+	// finder queries the routing tables of the live nodes for the peer
+	// graph. Routing tables are not queried if it is nil.
+	finder neighborFinder
+	graph  *peergraph.Graph
+	// enumerations are the nodes waiting for the enumeration workers.
+	enumerations chan *enode.Node
+
+	// protocol is the discovery protocol of disc and the iterators.
+	protocol  string
 	inputIter enode.Iterator
 	iters     []enode.Iterator
 
-	ch     chan *enode.Node
+	ch     chan foundNode
 	closed chan struct{}
+	// interrupted is closed if the crawl ends by timeout or cancellation,
+	// which cuts the queries of the routing tables short.
+	interrupted chan struct{}
 
 	// settings
 	revalidateInterval time.Duration
-
-	reqCh   chan *enode.Node
+	handshake          handshakeConfig
+
+	// queue holds the nodes to dial.
+	queue *dialQueue
+	// pending are the nodes with an ENR request in flight.
+	pending map[enode.ID]struct{}
+	// dials are the nodes which are queued, being dialed, or waiting for
+	// the timer of their retry.
+	dials map[enode.ID]*time.Timer
+	// found are the nodes returned by the lookups of this run, the capture
+	// for the size estimates.
+	found map[enode.ID]struct{}
+	// foreign are the live nodes of other networks, by their record.
+	foreign map[enode.ID]struct{}
+	retry   backoff
 	workers uint64
 
 	sync.WaitGroup
 	sync.RWMutex
 }
 
+// foundNode is a node returned by one of the iterators.
+type foundNode struct {
+	n *enode.Node
+	// found is true if the node was found by discovery, and false if it is
+	// from the input set.
+	found bool
+}
+
 type resolver interface {
 	RequestENR(*enode.Node) (*enode.Node, error)
 	RandomNodes() enode.Iterator
@@ -79,7 +180,7 @@ type resolver interface {
 func NewCrawler(
 	genesis *core.Genesis,
 	networkID uint64,
-	nodeURL string,
+	status StatusProvider,
 	input common.NodeSet,
 	workers uint64,
 	disc resolver,
@@ -87,16 +188,30 @@ func NewCrawler(
 ) *crawler {
 	c := &crawler{
 		output:    make(common.NodeSet, len(input)),
-		genesis:   genesis,
-		networkID: networkID,
-		nodeURL:   nodeURL,
+		enrless:   make(common.NodeSet),
+		status:    newChainStatus(genesis, networkID, status),
 		disc:      disc,
 		iters:     iters,
 		inputIter: enode.IterNodes(input.Nodes()),
-		ch:        make(chan *enode.Node),
-		reqCh:     make(chan *enode.Node, 512), // TODO: define this in config
+		ch:        make(chan foundNode),
+		queue:     newDialQueue(defaultDialQueueSize),
+		pending:   make(map[enode.ID]struct{}),
+		dials:     make(map[enode.ID]*time.Timer),
+		found:     make(map[enode.ID]struct{}),
+		foreign:   make(map[enode.ID]struct{}),
+		scoring:   DefaultScoring{},
+		graph:     peergraph.New(),
 		workers:   workers,
 		closed:    make(chan struct{}),
+
+		interrupted:  make(chan struct{}),
+		enumerations: make(chan *enode.Node, enumerateQueueSize),
+	}
+	if c.workers == 0 {
+		c.workers = 1
+	}
+	c.clientInfo = func(n *enode.Node) (*common.ClientInfo, error) {
+		return getClientInfo(c.status, n, c.handshake)
 	}
 	c.iters = append(c.iters, c.inputIter)
 	// Copy input to output initially. Any nodes that fail validation
@@ -107,13 +222,23 @@ func NewCrawler(
 	return c
 }
 
-func (c *crawler) Run(timeout time.Duration) common.NodeSet {
+// Run crawls the network until all iterators are exhausted, the timeout
+// fires, or the context is cancelled. The nodes checked so far are returned
+// in all cases.
+//
+// Nodes found by the iterators are validated by a pool of workers requesting
+// their records, and the live nodes are then dialed by a second pool of the
+// same size. If routing tables are queried, that is done by a third pool,
+// which finishes the queued nodes unless the crawl is cut short.
+func (c *crawler) Run(ctx context.Context, timeout time.Duration) common.NodeSet {
 	var (
 		timeoutTimer = time.NewTimer(timeout)
 		timeoutCh    <-chan time.Time
 		doneCh       = make(chan enode.Iterator, len(c.iters))
 		liveIters    = len(c.iters)
 		inputSetLen  = len(c.output)
+		enrWorkers   sync.WaitGroup
+		enumWorkers  sync.WaitGroup
 	)
 	defer timeoutTimer.Stop()
 
@@ -121,16 +246,26 @@ func (c *crawler) Run(timeout time.Duration) common.NodeSet {
 		go c.runIterator(doneCh, it)
 	}
 
+	for i := c.workers; i > 0; i-- {
+		enrWorkers.Add(1)
+		go c.requestENRLoop(ctx, &enrWorkers)
+	}
+
 	for i := c.workers; i > 0; i-- {
 		c.Add(1)
-		go c.getClientInfoLoop()
+		go c.getClientInfoLoop(ctx)
+	}
+
+	if c.finder != nil {
+		for i := c.workers; i > 0; i-- {
+			enumWorkers.Add(1)
+			go c.enumerateLoop(&enumWorkers)
+		}
 	}
 
 loop:
 	for {
 		select {
-		case n := <-c.ch:
-			c.updateNode(n)
 		case it := <-doneCh:
 			if it == c.inputIter {
 				// Enable timeout when we're done revalidating the input nodes.
@@ -143,19 +278,31 @@ loop:
 				break loop
 			}
 		case <-timeoutCh:
+			close(c.interrupted)
+			break loop
+		case <-ctx.Done():
+			log.Info("Crawl interrupted", "err", ctx.Err())
+			close(c.interrupted)
 			break loop
 		}
 	}
 
 	close(c.closed)
-	close(c.reqCh)
+	c.stopRetries()
 	for _, it := range c.iters {
 		it.Close()
 	}
 	for ; liveIters > 0; liveIters-- {
 		<-doneCh
 	}
+	// The dial and enumeration queues are closed once no more nodes can be
+	// added to them.
+	enrWorkers.Wait()
+	log.Info("Closing dial queue", "queued", c.queue.len())
+	c.queue.close()
+	close(c.enumerations)
 	c.Wait()
+	enumWorkers.Wait()
 
 	close(c.ch)
 
@@ -166,34 +313,68 @@ func (c *crawler) runIterator(done chan<- enode.Iterator, it enode.Iterator) {
 	defer func() { done <- it }()
 	for it.Next() {
 		select {
-		case c.ch <- it.Node():
+		case c.ch <- foundNode{n: it.Node(), found: it != c.inputIter}:
 		case <-c.closed:
 			return
 		}
 	}
 }
 
-func (c *crawler) getClientInfoLoop() {
-	defer func() { c.Done() }()
-	for n := range c.reqCh {
-		if n == nil {
+func (c *crawler) requestENRLoop(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		select {
+		case fn := <-c.ch:
+			c.updateNode(ctx, fn)
+		case <-c.closed:
 			return
 		}
+	}
+}
 
-		var tooManyPeers bool
-		var scoreInc int
+func (c *crawler) getClientInfoLoop(ctx context.Context) {
+	defer func() { c.Done() }()
+	for {
+		req, ok := c.queue.pop()
+		if !ok {
+			return
+		}
+		n := req.n
+		// Drain the queue without dialing once we are shutting down.
+		if ctx.Err() != nil {
+			continue
+		}
 
-		info, err := getClientInfo(c.genesis, c.networkID, c.nodeURL, n)
+		var failure *common.DialFailure
+		info, err := c.clientInfo(n)
 		if err != nil {
-			log.Warn("GetClientInfo failed", "error", err, "nodeID", n.ID())
-			if strings.Contains(err.Error(), "too many peers") {
-				tooManyPeers = true
+			var dialErr *DialError
+			if errors.As(err, &dialErr) {
+				failure = &dialErr.DialFailure
+			} else {
+				failure = &common.DialFailure{Kind: common.FailKindOther}
 			}
-		} else {
-			scoreInc = 10
+			log.Warn("GetClientInfo failed", "error", err, "failure", failure, "nodeID", n.ID())
+		}
+
+		// Failed dials return the info read before the failure, only its
+		// timings are kept.
+		var timings common.Timings
+		if info != nil {
+			timings = info.Timings
+		}
+		timings.RequestENR = req.enrTime
+		if err != nil {
+			info = nil
+		}
+
+		// Only nodes which sent us their status have a fork ID.
+		if info != nil && info.NetworkID != 0 && c.forks != nil {
+			info.ForkCompat = c.forks.classify(info.ForkID)
 		}
 
 		if info != nil {
+			info.Timings = timings
 			log.Info(
 				"Updating node info",
 				"client_type", info.ClientType,
@@ -201,116 +382,401 @@ func (c *crawler) getClientInfoLoop() {
 				"network_id", info.NetworkID,
 				"caps", info.Capabilities,
 				"fork_id", info.ForkID,
+				"fork_compat", info.ForkCompat,
 				"height", info.Blockheight,
 				"td", info.TotalDifficulty,
 				"head", info.HeadHash,
+				"earliest_block", info.EarliestBlock,
 			)
 		}
 
 		c.Lock()
-		node := c.output[n.ID()]
+		delete(c.dials, n.ID())
+		set := c.output
+		if req.enrless {
+			set = c.enrless
+		}
+		node := set[n.ID()]
 		node.N = n
 		node.Seq = n.Seq()
+		node.Failure = failure
+		node.Timings = &timings
+		node.TooManyPeers = failure != nil && failure.TooManyPeers()
+		var retryAt time.Time
+		switch {
+		case err == nil:
+			info.DialAttempts = node.BusyAttempts + 1
+			node.LastClientInfo = time.Now().UTC().Truncate(time.Second)
+			node.BusyAttempts, node.NextDial = 0, time.Time{}
+		case node.TooManyPeers:
+			node.BusyAttempts++
+			if c.retry.enabled() {
+				node.NextDial = time.Now().Add(c.retry.delay(node.BusyAttempts)).UTC()
+				retryAt = node.NextDial
+			}
+		default:
+			node.BusyAttempts, node.NextDial = 0, time.Time{}
+		}
 		if info != nil {
 			node.Info = info
 		}
-		node.TooManyPeers = tooManyPeers
-		node.Score += scoreInc
-		c.output[n.ID()] = node
+		c.scoring.Dialed(&node, err == nil)
+		node.RecordScore(common.CheckDial, err == nil)
+		set[n.ID()] = node
+		priority := newDialPriority(false, node)
+		priority.foreign = req.priority.foreign
 		c.Unlock()
+
+		if !retryAt.IsZero() {
+			log.Debug("Retrying busy node", "id", n.ID(), "attempts", node.BusyAttempts, "at", retryAt)
+			c.scheduleDial(ctx, dialRequest{n: n, priority: priority, enrless: req.enrless}, retryAt)
+		}
 	}
 }
 
-func (c *crawler) updateNode(n *enode.Node) {
+// updateNode requests the record of the node, and queues it for dialing if
+// it is still live. The routing tables of live nodes are queried afterwards
+// in deep mode. The lock is not held during the request, or while
+// waiting for room in the dial queue.
+func (c *crawler) updateNode(ctx context.Context, fn foundNode) {
+	n := fn.n
+	if !c.startCheck(n.ID(), fn.found) {
+		return
+	}
+
+	// Request the node record.
+	start := time.Now()
+	nn, err := c.disc.RequestENR(n)
+	enrTime := time.Since(start)
+
+	req, dialAt, ok := c.finishCheck(n, nn, err, start, fn.found)
+	if !ok {
+		return
+	}
+	if err == nil {
+		req.enrTime = enrTime
+	}
+	if req.foreign && c.forkFilter == ForkFilterSkip {
+		log.Debug("Skipping node of another network", "id", n.ID())
+		foreignSkipped.Inc(1)
+	} else {
+		c.scheduleDial(ctx, req, dialAt)
+	}
+	// Only nodes answering the ENR request have the endpoint proof needed
+	// to query their routing table with discv4.
+	if err == nil && c.finder != nil {
+		c.queueEnumerate(n)
+	}
+}
+
+// startCheck reports whether the node should be checked, and marks it as
+// pending if so. Recently-seen nodes, nodes which are already being checked,
+// and nodes waiting to be dialed are skipped. Known nodes are marked as found
+// by the protocol in any case.
+func (c *crawler) startCheck(id enode.ID, found bool) bool {
 	c.Lock()
 	defer c.Unlock()
 
-	node, ok := c.output[n.ID()]
+	if found {
+		c.found[id] = struct{}{}
+	}
+	if node, ok := c.output[id]; ok && found && !node.Discovery[c.protocol].Found {
+		d := node.Discovery[c.protocol]
+		d.Found = true
+		node.SetDiscovery(c.protocol, d)
+		c.output[id] = node
+	}
 
-	// Skip validation of recently-seen nodes.
+	if _, ok := c.pending[id]; ok {
+		return false
+	}
+	if _, ok := c.dials[id]; ok {
+		return false
+	}
+	node, ok := c.output[id]
 	if ok && !node.TooManyPeers && time.Since(node.LastCheck) < c.revalidateInterval {
-		return
+		return false
+	}
+	if node, ok := c.enrless[id]; ok && time.Since(node.LastCheck) < c.revalidateInterval {
+		return false
 	}
+	c.pending[id] = struct{}{}
+	return true
+}
+
+// finishCheck stores the result of the ENR request started at the given
+// time. It reports whether the node is still live and should be dialed, and
+// returns the request to queue at the returned time. Busy nodes are dialed
+// once their backoff expires, which may have been set in an earlier round.
+func (c *crawler) finishCheck(n, nn *enode.Node, err error, start time.Time, found bool) (dialRequest, time.Time, bool) {
+	c.Lock()
+	defer c.Unlock()
 
-	node.LastCheck = time.Now().UTC().Truncate(time.Second)
+	delete(c.pending, n.ID())
+	node, known := c.output[n.ID()]
+	node.LastCheck = start.UTC().Truncate(time.Second)
 
-	// Request the node record.
-	nn, err := c.disc.RequestENR(n)
 	if err != nil {
-		if node.Score == 0 {
+		if !known {
 			// Node doesn't implement EIP-868.
+			if c.dialENRLess && found {
+				return c.addENRLess(n, node.LastCheck), time.Time{}, true
+			}
 			log.Debug("Skipping node", "id", n.ID())
-			return
+			return dialRequest{}, time.Time{}, false
 		}
-		node.Score /= 2
 	} else {
 		node.N = nn
 		node.Seq = nn.Seq()
-		node.Score++
 		if node.FirstResponse.IsZero() {
 			node.FirstResponse = node.LastCheck
 		}
 		node.LastResponse = node.LastCheck
 	}
+	d := node.Discovery[c.protocol]
+	d.Found = d.Found || found
+	d.LastCheck = node.LastCheck
+	if err == nil {
+		d.LastResponse = node.LastCheck
+	}
+	node.SetDiscovery(c.protocol, d)
+
+	c.scoring.ENRChecked(&node, err == nil)
+	node.RecordScore(common.CheckENR, err == nil)
 
 	// Store/update node in output set.
-	if node.Score <= 0 {
-		log.Info("Removing node", "id", n.ID())
+	if !c.scoring.Keep(&node) {
+		log.Info("Removing node", "id", n.ID(), "score", node.Score, "history", node.ScoreHistory)
 		delete(c.output, n.ID())
-	} else {
-		log.Info("Updating node", "id", n.ID(), "seq", n.Seq(), "score", node.Score)
-		c.reqCh <- n
-		c.output[n.ID()] = node
+		return dialRequest{}, time.Time{}, false
 	}
+	log.Info("Updating node", "id", n.ID(), "seq", n.Seq(), "score", node.Score)
+	c.output[n.ID()] = node
+
+	var dialAt time.Time
+	if node.TooManyPeers {
+		dialAt = node.NextDial
+	}
+	req := dialRequest{n: n, priority: newDialPriority(!known, node)}
+	if c.foreignNetwork(node.N) {
+		c.foreign[n.ID()] = struct{}{}
+		req.foreign = true
+		req.priority.foreign = c.forkFilter == ForkFilterDeprioritize
+	}
+	return req, dialAt, true
+}
+
+// addENRLess stores a node which doesn't answer ENR requests, and returns
+// the request to dial it. These nodes are dialed after all others. The lock
+// must be held.
+func (c *crawler) addENRLess(n *enode.Node, checked time.Time) dialRequest {
+	node := c.enrless[n.ID()]
+	node.N = n
+	node.Seq = n.Seq()
+	node.ENRLess = true
+	node.LastCheck = checked
+	d := node.Discovery[c.protocol]
+	d.Found = true
+	d.LastCheck = checked
+	node.SetDiscovery(c.protocol, d)
+	c.enrless[n.ID()] = node
+
+	log.Debug("Dialing node without ENR", "id", n.ID(), "addr", n.IPAddr())
+	return dialRequest{n: n, priority: newDialPriority(false, node), enrless: true}
 }
 
+// enrlessNodes returns the nodes without ENR which we could connect to.
+func (c *crawler) enrlessNodes() common.NodeSet {
+	c.Lock()
+	defer c.Unlock()
+
+	nodes := make(common.NodeSet)
+	for id, n := range c.enrless {
+		if n.Info != nil || n.Failure != nil && n.Failure.Phase != common.FailPhaseConnect {
+			nodes[id] = n
+		}
+	}
+	return nodes
+}
+
+// CrawlRound runs one discv4 and one discv5 crawl in parallel and writes the
+// merged result to the database. If the context is cancelled, the round is
+// cut short, and the nodes found so far are still written and returned.
+// Nodes without ENR are written to the database, but not returned.
+//
+// The size of the network is estimated from the overlap of the two crawls,
+// and from the overlap with the previous round if Estimator is set. Rounds
+// which are cut short are not used for estimates.
 func (c Crawler) CrawlRound(
+	ctx context.Context,
 	inputSet common.NodeSet,
 	db *sql.DB,
 	geoipDB *geoip2.Reader,
-) common.NodeSet {
-	var v4, v5 common.NodeSet
+	asnDB *geoip2.Reader,
+) (common.NodeSet, error) {
+	var v4, v5 crawlResult
 	var wg sync.WaitGroup
 
+	round := time.Now().UTC().Truncate(time.Second)
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		v5 = c.discv5(inputSet)
-		log.Info("DiscV5", "nodes", len(v5.Nodes()))
+		v5 = c.discv5(ctx, inputSet)
+		log.Info("DiscV5", "nodes", len(v5.output.Nodes()), "found", len(v5.found), "foreign", len(v5.foreign))
 	}()
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		v4 = c.discv4(inputSet)
-		log.Info("DiscV4", "nodes", len(v4.Nodes()))
+		v4 = c.discv4(ctx, inputSet)
+		log.Info("DiscV4", "nodes", len(v4.output.Nodes()), "found", len(v4.found), "foreign", len(v4.foreign), "enrless", len(v4.enrless))
 	}()
 
 	wg.Wait()
 
-	output := make(common.NodeSet, len(v5)+len(v4))
-	for _, n := range v5 {
+	// Nodes found by both protocols keep the liveness data of each.
+	output := make(common.NodeSet, len(v5.output)+len(v4.output))
+	for _, n := range v5.output {
 		output[n.N.ID()] = n
 	}
-	for _, n := range v4 {
+	for _, n := range v4.output {
+		if n5, ok := output[n.N.ID()]; ok {
+			n.Discovery = mergeDiscovery(n5.Discovery, n.Discovery)
+		}
 		output[n.N.ID()] = n
 	}
 
+	// Nodes of other networks are counted once, even if both crawls found
+	// them.
+	foreign := len(v4.foreign)
+	for id := range v5.foreign {
+		if _, ok := v4.foreign[id]; !ok {
+			foreign++
+		}
+	}
+	foreignRound.Update(int64(foreign))
+	log.Info("Foreign network nodes", "count", foreign, "filter", c.ForkFilter)
+
 	var nodes []common.NodeJSON
 	for _, node := range output {
 		nodes = append(nodes, node)
 	}
+	for id, node := range v4.enrless {
+		// The node may have answered the discv5 ENR requests.
+		if _, ok := output[id]; !ok {
+			nodes = append(nodes, node)
+		}
+	}
+
+	if c.DeepCrawl {
+		graph := peergraph.New()
+		graph.Merge(v4.graph)
+		graph.Merge(v5.graph)
+		enumerated, edges := graph.Len()
+		log.Info("Peer graph", "enumerated", enumerated, "edges", edges)
+		if c.GraphDir != "" {
+			if err := c.writeGraph(round, graph, output); err != nil {
+				log.Error("Failed to write peer graph", "err", err)
+			}
+		}
+	}
 
-	// Write the node info to influx
+	var estimates []estimate.SizeEstimate
+	if ctx.Err() == nil {
+		estimates = c.estimateSize(output, v4.found, v5.found)
+	}
+	chains := c.countChains(nodes)
+
+	// Write the node info to the database
 	if db != nil {
-		if err := crawlerdb.UpdateNodes(db, geoipDB, nodes); err != nil {
-			panic(err)
+		if err := crawlerdb.UpdateNodes(db, geoipDB, asnDB, c.Chains, nodes); err != nil {
+			return output, fmt.Errorf("error writing nodes: %w", err)
+		}
+		if err := crawlerdb.InsertEstimates(db, round, estimates); err != nil {
+			return output, fmt.Errorf("error writing estimates: %w", err)
+		}
+		if err := crawlerdb.InsertChainCounts(db, round, chains); err != nil {
+			return output, fmt.Errorf("error writing chain counts: %w", err)
+		}
+	}
+	return output, nil
+}
+
+// writeGraph writes the peer graph of the round started at the given time
+// to a new file in GraphDir.
+func (c Crawler) writeGraph(round time.Time, graph *peergraph.Graph, output common.NodeSet) error {
+	name := filepath.Join(c.GraphDir, fmt.Sprintf("peers-%s.%s", round.Format("20060102T150405Z"), c.GraphFormat))
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	client := func(id enode.ID) string {
+		if n, ok := output[id]; ok && n.Info != nil {
+			return estimate.GroupOf(n).Client
+		}
+		return ""
+	}
+	if err := peergraph.Write(f, c.GraphFormat, graph, client); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing %s: %w", name, err)
+	}
+	log.Info("Wrote peer graph", "file", name)
+	return f.Close()
+}
+
+// estimateSize estimates the size of the network from the nodes found by
+// the discv4 and discv5 lookups. Only nodes in the output are counted, as the
+// others are not live or their client is not known.
+func (c Crawler) estimateSize(output common.NodeSet, v4, v5 map[enode.ID]struct{}) []estimate.SizeEstimate {
+	allCapture := make(estimate.Capture, len(v4)+len(v5))
+	capture := func(found map[enode.ID]struct{}) estimate.Capture {
+		c := make(estimate.Capture, len(found))
+		for id := range found {
+			if n, ok := output[id]; ok {
+				c[id] = estimate.GroupOf(n)
+				allCapture[id] = c[id]
+			}
+		}
+		return c
+	}
+	v4Capture, v5Capture := capture(v4), capture(v5)
+
+	var estimates []estimate.SizeEstimate
+	if len(v4Capture) > 0 && len(v5Capture) > 0 {
+		estimates = estimate.Compare(estimate.MethodDiscovery, v4Capture, v5Capture)
+	}
+	if c.Estimator != nil {
+		estimates = append(estimates, c.Estimator.Next(allCapture)...)
+	}
+	for _, e := range estimates {
+		if e.Client == "all" {
+			log.Info("Estimated network size", "method", e.Method, "network", e.NetworkID,
+				"size", int(e.Size), "lower", int(e.Lower), "upper", int(e.Upper))
 		}
 	}
-	return output
+	return estimates
 }
 
-func (c Crawler) discv5(inputSet common.NodeSet) common.NodeSet {
+// countChains returns the number of nodes of every chain and fork stage.
+func (c Crawler) countChains(nodes []common.NodeJSON) map[networks.Label]int {
+	if c.Chains == nil {
+		return nil
+	}
+	counts := make(map[networks.Label]int)
+	perChain := make(map[string]int)
+	for _, n := range nodes {
+		label := c.Chains.LabelNode(n)
+		counts[label]++
+		perChain[label.Chain]++
+	}
+	for chain, count := range perChain {
+		log.Info("Nodes of chain", "chain", chain, "nodes", count, "share", fmt.Sprintf("%.1f%%", 100*float64(count)/float64(len(nodes))))
+	}
+	return counts
+}
+
+func (c Crawler) discv5(ctx context.Context, inputSet common.NodeSet) crawlResult {
 	ln, config := c.makeDiscoveryConfig()
 
 	socket := listen(ln, c.ListenAddr)
@@ -321,43 +787,121 @@ func (c Crawler) discv5(inputSet common.NodeSet) common.NodeSet {
 	}
 	defer disc.Close()
 
-	return c.runCrawler(disc, inputSet)
+	var finder neighborFinder
+	if c.DeepCrawl {
+		finder = v5Finder{disc}
+	}
+	return c.runCrawler(ctx, common.DiscV5, disc, finder, inputSet, disc.RandomNodes())
 }
 
-func (c Crawler) discv4(inputSet common.NodeSet) common.NodeSet {
+// discv4 crawls with discv4. Only discv4 crawls have nodes without ENR.
+func (c Crawler) discv4(ctx context.Context, inputSet common.NodeSet) crawlResult {
 	ln, config := c.makeDiscoveryConfig()
 
-	socket := listen(ln, c.ListenAddr)
+	var (
+		socket                  = listen(ln, c.ListenAddr)
+		conn   discover.UDPConn = socket
+		finder neighborFinder
+	)
+	if c.DeepCrawl {
+		f := newV4Finder(socket, config.PrivateKey)
+		conn, finder = f, f
+	}
 
-	disc, err := discover.ListenV4(socket, ln, config)
+	disc, err := discover.ListenV4(conn, ln, config)
 	if err != nil {
 		panic(err)
 	}
 	defer disc.Close()
 
-	return c.runCrawler(disc, inputSet)
+	iters := []enode.Iterator{disc.RandomNodes()}
+	// The DNS lists only contain nodes found via discv4.
+	if len(c.Network.DNSRoots) > 0 {
+		dnsIter, err := dnsdisc.NewClient(dnsdisc.Config{}).NewIterator(c.Network.DNSRoots...)
+		if err != nil {
+			panic(err)
+		}
+		iters = append(iters, dnsIter)
+	}
+
+	return c.runCrawler(ctx, common.DiscV4, disc, finder, inputSet, iters...)
 }
 
-func (c Crawler) runCrawler(disc resolver, inputSet common.NodeSet) common.NodeSet {
-	genesis := c.makeGenesis()
-	if genesis == nil {
-		genesis = core.DefaultGenesisBlock()
-	}
+// crawlResult is the outcome of the crawl of one discovery protocol.
+type crawlResult struct {
+	output common.NodeSet
+	// enrless are the nodes without ENR which could be dialed.
+	enrless common.NodeSet
+	// found are the nodes returned by the lookups.
+	found map[enode.ID]struct{}
+	// graph is empty unless the routing tables were queried.
+	graph *peergraph.Graph
+	// foreign are the live nodes of other networks.
+	foreign map[enode.ID]struct{}
+}
 
-	crawler := NewCrawler(genesis, c.NetworkID, c.NodeURL, inputSet, c.Workers, disc, disc.RandomNodes())
+func (c Crawler) runCrawler(
+	ctx context.Context,
+	protocol string,
+	disc resolver,
+	finder neighborFinder,
+	inputSet common.NodeSet,
+	iters ...enode.Iterator,
+) crawlResult {
+	crawler := NewCrawler(c.Network.Genesis, c.networkID(), c.Status, inputSet, c.Workers, disc, iters...)
+	crawler.protocol = protocol
 	crawler.revalidateInterval = 10 * time.Minute
-	return crawler.Run(c.Timeout)
+	crawler.handshake = handshakeConfig{
+		key:          c.RLPxKey,
+		name:         c.ClientName,
+		listenPort:   c.ListenPort,
+		dialFallback: c.DialFallback,
+		snapProbe:    c.SnapProbe,
+	}
+	crawler.forks = newForkChecker(c.Network, crawler.status, c.Chains)
+	crawler.forkFilter = c.ForkFilter
+	crawler.queue = newDialQueue(c.DialQueueSize)
+	crawler.retry = backoff{base: c.RetryDelay, max: c.RetryMaxDelay}
+	if c.Scoring != nil {
+		crawler.scoring = c.Scoring
+	}
+	// Only discv4 nodes may not support ENR requests.
+	crawler.dialENRLess = c.DialENRLess && protocol == common.DiscV4
+	crawler.finder = finder
+	output := crawler.Run(ctx, c.Timeout)
+	return crawlResult{
+		output:  output,
+		enrless: crawler.enrlessNodes(),
+		found:   crawler.found,
+		graph:   crawler.graph,
+		foreign: crawler.foreign,
+	}
 }
 
-// makeGenesis is the pendant to utils.MakeGenesis
-// with local flags instead of global flags.
-func (c Crawler) makeGenesis() *core.Genesis {
-	if c.Sepolia {
-		return core.DefaultSepoliaGenesisBlock()
+// mergeDiscovery merges the liveness data of two crawls. The more recent
+// check and response of every protocol are kept.
+func mergeDiscovery(a, b map[string]common.DiscoveryLiveness) map[string]common.DiscoveryLiveness {
+	merged := make(map[string]common.DiscoveryLiveness, len(a)+len(b))
+	for p, d := range a {
+		merged[p] = d
 	}
-	if c.Hoodi {
-		return core.DefaultHoodiGenesisBlock()
+	for p, d := range b {
+		prev := merged[p]
+		if prev.LastCheck.After(d.LastCheck) {
+			d.LastCheck = prev.LastCheck
+		}
+		if prev.LastResponse.After(d.LastResponse) {
+			d.LastResponse = prev.LastResponse
+		}
+		d.Found = d.Found || prev.Found
+		merged[p] = d
 	}
+	return merged
+}
 
-	return core.DefaultGenesisBlock()
+func (c Crawler) networkID() uint64 {
+	if c.NetworkID != 0 {
+		return c.NetworkID
+	}
+	return c.Network.NetworkID
 }
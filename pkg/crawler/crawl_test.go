@@ -0,0 +1,374 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/node-crawler/pkg/common"
+	"github.com/ethereum/node-crawler/pkg/enrattr"
+	"github.com/ethereum/node-crawler/pkg/networks"
+	"github.com/ethereum/node-crawler/pkg/simnet"
+)
+
+func newTestCrawler(nw *simnet.Network, input common.NodeSet) *crawler {
+	c := NewCrawler(core.DefaultGenesisBlock(), 1, nil, input, 8, nw, nw.RandomNodes())
+	c.protocol = common.DiscV4
+	c.revalidateInterval = time.Hour
+	c.clientInfo = func(n *enode.Node) (*common.ClientInfo, error) {
+		return &common.ClientInfo{ClientType: "Geth/v1.15.9-stable/linux-amd64/go1.24.2"}, nil
+	}
+	return c
+}
+
+func TestRunDiscovery(t *testing.T) {
+	nw := simnet.New(simnet.Config{
+		Nodes:         5000,
+		Seed:          1,
+		NoENRRatio:    0.1,
+		OfflineRatio:  0.1,
+		IteratorLimit: 20000,
+	})
+
+	c := newTestCrawler(nw, nil)
+	output := c.Run(context.Background(), time.Minute)
+	if len(output) == 0 {
+		t.Fatal("no nodes found")
+	}
+	for id, n := range output {
+		if !nw.Online(id) {
+			t.Errorf("offline node %v in output", id)
+		}
+		if _, ok := c.found[id]; !ok {
+			t.Errorf("node %v missing in the found set", id)
+		}
+		if n.Score != 11 {
+			t.Errorf("wrong score for node %v: got %d, want 11", id, n.Score)
+		}
+		if n.Info == nil {
+			t.Errorf("missing client info for node %v", id)
+		}
+		if d := n.Discovery[common.DiscV4]; !d.Found || !d.Alive() {
+			t.Errorf("wrong discovery liveness for node %v: %+v", id, d)
+		}
+	}
+}
+
+func TestRunRevalidation(t *testing.T) {
+	nw := simnet.New(simnet.Config{Nodes: 2000, Seed: 2, IteratorLimit: 1})
+
+	var (
+		input   = make(common.NodeSet)
+		offline = make(map[enode.ID]bool)
+		old     = time.Now().Add(-24 * time.Hour)
+	)
+	for i, n := range nw.Nodes() {
+		input[n.ID()] = common.NodeJSON{N: n, Seq: n.Seq(), Score: 1, LastCheck: old}
+		if i%2 == 0 {
+			nw.SetOnline(n.ID(), false)
+			offline[n.ID()] = true
+		}
+	}
+
+	// The ENR workers wait for room in the small dial queue, which they
+	// must do without holding the lock the dial workers need.
+	c := newTestCrawler(nw, input)
+	c.queue = newDialQueue(16)
+	output := c.Run(context.Background(), time.Minute)
+	for id := range offline {
+		if _, ok := output[id]; ok {
+			t.Errorf("offline node %v not removed", id)
+		}
+	}
+	for id, n := range input {
+		if offline[id] {
+			continue
+		}
+		if output[id].Score <= n.Score {
+			t.Errorf("score of live node %v not increased: %d", id, output[id].Score)
+		}
+		if supported, ok := output[id].SupportsEIP868(); !ok || !supported {
+			t.Errorf("live node %v not marked as supporting EIP-868", id)
+		}
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	nw := simnet.New(simnet.Config{Nodes: 1000, Seed: 3, Latency: time.Millisecond})
+	c := newTestCrawler(nw, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	done := make(chan common.NodeSet)
+	go func() { done <- c.Run(ctx, 0) }()
+
+	select {
+	case output := <-done:
+		if len(output) == 0 {
+			t.Fatal("no nodes returned from cancelled run")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("run did not stop after cancellation")
+	}
+}
+
+// concurrentResolver counts the ENR requests in flight.
+type concurrentResolver struct {
+	*simnet.Network
+	inflight, max atomic.Int64
+}
+
+func (r *concurrentResolver) RequestENR(n *enode.Node) (*enode.Node, error) {
+	cur := r.inflight.Add(1)
+	defer r.inflight.Add(-1)
+	for {
+		max := r.max.Load()
+		if cur <= max || r.max.CompareAndSwap(max, cur) {
+			break
+		}
+	}
+	return r.Network.RequestENR(n)
+}
+
+func TestRunParallelENR(t *testing.T) {
+	nw := simnet.New(simnet.Config{
+		Nodes:         500,
+		Seed:          4,
+		Latency:       5 * time.Millisecond,
+		IteratorLimit: 500,
+	})
+	res := &concurrentResolver{Network: nw}
+
+	c := newTestCrawler(nw, nil)
+	c.disc = res
+	// Dials block until the run is over, the ENR requests must not wait
+	// for them.
+	block := make(chan struct{})
+	c.clientInfo = func(n *enode.Node) (*common.ClientInfo, error) {
+		<-block
+		return nil, errNoEndpoint
+	}
+
+	done := make(chan common.NodeSet)
+	go func() { done <- c.Run(context.Background(), time.Minute) }()
+
+	deadline := time.After(10 * time.Second)
+	for res.max.Load() < int64(c.workers) {
+		select {
+		case <-deadline:
+			t.Fatalf("ENR requests not parallel, max %d in flight", res.max.Load())
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	close(block)
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("run did not finish")
+	}
+}
+
+func TestRunFailedDialTimings(t *testing.T) {
+	nw := simnet.New(simnet.Config{Nodes: 100, Seed: 6, Latency: time.Millisecond, IteratorLimit: 200})
+	c := newTestCrawler(nw, nil)
+	c.clientInfo = func(n *enode.Node) (*common.ClientInfo, error) {
+		info := &common.ClientInfo{ClientType: "Geth/v1.15.9-stable/linux-amd64/go1.24.2"}
+		info.Timings.Connect = 10 * time.Millisecond
+		return info, newDialError(common.FailPhaseHandshake, errors.New("handshake failed"))
+	}
+
+	output := c.Run(context.Background(), time.Minute)
+	if len(output) == 0 {
+		t.Fatal("no nodes found")
+	}
+	for id, n := range output {
+		if n.Info != nil {
+			t.Errorf("node %v has the info of a failed dial", id)
+		}
+		if n.Timings == nil || n.Timings.Connect != 10*time.Millisecond || n.Timings.RequestENR < time.Millisecond {
+			t.Errorf("wrong timings for node %v: %+v", id, n.Timings)
+		}
+	}
+}
+
+func TestMergeDiscovery(t *testing.T) {
+	var (
+		old = time.Now().Add(-time.Hour)
+		now = time.Now()
+	)
+	v5 := map[string]common.DiscoveryLiveness{
+		common.DiscV4: {LastCheck: now},
+		common.DiscV5: {Found: true, LastCheck: now, LastResponse: now},
+	}
+	v4 := map[string]common.DiscoveryLiveness{
+		common.DiscV4: {Found: true, LastCheck: old, LastResponse: old},
+		common.DiscV5: {LastCheck: old},
+	}
+	merged := mergeDiscovery(v5, v4)
+
+	node := common.NodeJSON{Discovery: merged}
+	if p := node.DiscoveryProtocols(); p != "discv4,discv5" {
+		t.Errorf("wrong protocols %q", p)
+	}
+	if d := merged[common.DiscV4]; !d.LastCheck.Equal(now) || d.Alive() {
+		t.Errorf("discv4 check not the latest: %+v", d)
+	}
+	if d := merged[common.DiscV5]; !d.Alive() {
+		t.Errorf("discv5 check not the latest: %+v", d)
+	}
+	if supported, ok := node.SupportsEIP868(); !ok || !supported {
+		t.Error("EIP-868 support lost")
+	}
+}
+
+func TestRunDialENRLess(t *testing.T) {
+	nw := simnet.New(simnet.Config{
+		Nodes:         300,
+		Seed:          7,
+		NoENRRatio:    0.3,
+		IteratorLimit: 1000,
+	})
+
+	c := newTestCrawler(nw, nil)
+	c.dialENRLess = true
+	output := c.Run(context.Background(), time.Minute)
+
+	enrless := c.enrlessNodes()
+	if len(enrless) == 0 {
+		t.Fatal("no nodes without ENR dialed")
+	}
+	for id, n := range enrless {
+		if _, ok := output[id]; ok {
+			t.Errorf("node %v without ENR in output", id)
+		}
+		if !n.ENRLess || n.Info == nil {
+			t.Errorf("node %v without ENR not dialed: %+v", id, n)
+		}
+		if supported, ok := n.SupportsEIP868(); !ok || supported {
+			t.Errorf("node %v without ENR marked as supporting EIP-868", id)
+		}
+	}
+	for id, n := range output {
+		if n.ENRLess {
+			t.Errorf("node %v with ENR marked as ENR-less", id)
+		}
+	}
+}
+
+// recordResolver answers ENR requests with the record the node was found
+// with.
+type recordResolver struct{}
+
+func (recordResolver) RequestENR(n *enode.Node) (*enode.Node, error) { return n, nil }
+func (recordResolver) RandomNodes() enode.Iterator                   { return enode.IterNodes(nil) }
+
+func TestRunForkFilter(t *testing.T) {
+	mainnet, err := networks.Lookup("mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := forkid.ID{Hash: [4]byte{0xde, 0xad, 0xbe, 0xef}}
+
+	var (
+		nodes   []*enode.Node
+		foreign = make(map[enode.ID]bool)
+	)
+	for i := 0; i < 60; i++ {
+		var r enr.Record
+		r.Set(enr.IPv4(net.IPv4(10, 0, 0, byte(i))))
+		r.Set(enr.TCP(30303))
+		switch i % 3 {
+		case 0:
+			r.Set(enrattr.Eth{ForkID: mainnet.ForkIDs[len(mainnet.ForkIDs)-1]})
+		case 1:
+			r.Set(enrattr.Eth{ForkID: other})
+		}
+		key, _ := crypto.GenerateKey()
+		if err := enode.SignV4(&r, key); err != nil {
+			t.Fatal(err)
+		}
+		n, err := enode.New(enode.ValidSchemes, &r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, n)
+		foreign[n.ID()] = i%3 == 1
+	}
+
+	for _, filter := range []ForkFilter{ForkFilterOff, ForkFilterSkip, ForkFilterDeprioritize} {
+		t.Run(filter.String(), func(t *testing.T) {
+			c := NewCrawler(mainnet.Genesis, mainnet.NetworkID, nil, nil, 4, recordResolver{}, enode.IterNodes(nodes))
+			c.protocol = common.DiscV4
+			c.forks = newForkChecker(mainnet, c.status, nil)
+			c.forkFilter = filter
+
+			var (
+				mu     sync.Mutex
+				dialed = make(map[enode.ID]bool)
+			)
+			c.clientInfo = func(n *enode.Node) (*common.ClientInfo, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				dialed[n.ID()] = true
+				return &common.ClientInfo{ClientType: "Geth/v1.15.9-stable/linux-amd64/go1.24.2"}, nil
+			}
+			output := c.Run(context.Background(), time.Minute)
+
+			if len(output) != len(nodes) {
+				t.Errorf("got %d nodes in the output, want %d", len(output), len(nodes))
+			}
+			for id, isForeign := range foreign {
+				if _, ok := c.foreign[id]; ok != isForeign {
+					t.Errorf("node %v counted as foreign: %t, want %t", id, ok, isForeign)
+				}
+				if want := !isForeign || filter != ForkFilterSkip; dialed[id] != want {
+					t.Errorf("node %v dialed: %t, want %t", id, dialed[id], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCountChains(t *testing.T) {
+	chains, err := networks.NewRegistry("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mainnet, _ := networks.Lookup("mainnet")
+	cancun := mainnet.ForkIDs[14]
+
+	nw := simnet.New(simnet.Config{Nodes: 3, Seed: 8})
+	n := nw.Nodes()
+	nodes := []common.NodeJSON{
+		{N: n[0], Info: &common.ClientInfo{NetworkID: 1, Genesis: mainnet.GenesisHash, ForkID: cancun}},
+		{N: n[1], Info: &common.ClientInfo{NetworkID: 1, Genesis: mainnet.GenesisHash, ForkID: cancun}},
+		{N: n[2]},
+	}
+	got := Crawler{Chains: chains}.countChains(nodes)
+	want := map[networks.Label]int{
+		{Chain: "mainnet", Stage: "Cancun"}: 2,
+		{Chain: networks.UnknownChain}:      1,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for label, count := range want {
+		if got[label] != count {
+			t.Errorf("%+v: got %d nodes, want %d", label, got[label], count)
+		}
+	}
+	if counts := (Crawler{}).countChains(nodes); counts != nil {
+		t.Errorf("nodes counted without chains: %v", counts)
+	}
+}
@@ -20,8 +20,6 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/hex"
-	"net"
-	"strconv"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -29,20 +27,20 @@ import (
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
-// parseNode parses a node record and verifies its signature.
-func parseNode(source string) (*enode.Node, error) {
+// ParseNode parses a node record and verifies its signature.
+func ParseNode(source string) (*enode.Node, error) {
 	if strings.HasPrefix(source, "enode://") {
 		return enode.ParseV4(source)
 	}
-	r, err := parseRecord(source)
+	r, err := ParseRecord(source)
 	if err != nil {
 		return nil, err
 	}
 	return enode.New(enode.ValidSchemes, r)
 }
 
-// parseRecord parses a node record from hex, base64, or raw binary input.
-func parseRecord(source string) (*enr.Record, error) {
+// ParseRecord parses a node record from hex, base64, or raw binary input.
+func ParseRecord(source string) (*enr.Record, error) {
 	bin := []byte(source)
 	if d, ok := decodeRecordHex(bytes.TrimSpace(bin)); ok {
 		bin = d
@@ -71,40 +69,3 @@ func decodeRecordBase64(b []byte) ([]byte, bool) {
 	n, err := base64.RawURLEncoding.Decode(dec, b)
 	return dec[:n], err == nil
 }
-
-// attrFormatters contains formatting functions for well-known ENR keys.
-var attrFormatters = map[string]func(rlp.RawValue) (string, bool){
-	"id":   formatAttrString,
-	"ip":   formatAttrIP,
-	"ip6":  formatAttrIP,
-	"tcp":  formatAttrUint,
-	"tcp6": formatAttrUint,
-	"udp":  formatAttrUint,
-	"udp6": formatAttrUint,
-}
-
-func formatAttrRaw(v rlp.RawValue) (string, bool) {
-	s := hex.EncodeToString(v)
-	return s, true
-}
-
-func formatAttrString(v rlp.RawValue) (string, bool) {
-	content, _, err := rlp.SplitString(v)
-	return strconv.Quote(string(content)), err == nil
-}
-
-func formatAttrIP(v rlp.RawValue) (string, bool) {
-	content, _, err := rlp.SplitString(v)
-	if err != nil || len(content) != 4 && len(content) != 6 {
-		return "", false
-	}
-	return net.IP(content).String(), true
-}
-
-func formatAttrUint(v rlp.RawValue) (string, bool) {
-	var x uint64
-	if err := rlp.DecodeBytes(v, &x); err != nil {
-		return "", false
-	}
-	return strconv.FormatUint(x, 10), true
-}
@@ -0,0 +1,82 @@
+package crawler
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/node-crawler/pkg/common"
+)
+
+var (
+	errNoEndpoint  = errors.New("node has no TCP endpoint")
+	errDecode      = errors.New("could not rlp decode message")
+	errInvalidCode = errors.New("invalid message code")
+	errConnLost    = errors.New("connection lost")
+)
+
+// DialError is returned when getting the client info of a node fails. It
+// classifies the failure, and wraps the underlying error.
+type DialError struct {
+	common.DialFailure
+	Err error
+}
+
+func (e *DialError) Error() string { return e.Err.Error() }
+func (e *DialError) Unwrap() error { return e.Err }
+
+// newDialError classifies the error which happened in the given phase.
+func newDialError(phase string, err error) *DialError {
+	return newDialErrorKind(phase, failureKind(err), err)
+}
+
+func newDialErrorKind(phase, kind string, err error) *DialError {
+	return &DialError{
+		DialFailure: common.DialFailure{Phase: phase, Kind: kind},
+		Err:         err,
+	}
+}
+
+// newDisconnectError returns the error for a disconnect message received in
+// the given phase.
+func newDisconnectError(phase string, reason p2p.DiscReason, err error) *DialError {
+	return &DialError{
+		DialFailure: common.DialFailure{
+			Phase:  phase,
+			Kind:   common.FailKindDisconnect,
+			Reason: reason,
+		},
+		Err: err,
+	}
+}
+
+func failureKind(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errNoEndpoint):
+		return common.FailKindNoEndpoint
+	case errors.Is(err, errDecode):
+		return common.FailKindDecode
+	case errors.Is(err, errInvalidCode):
+		return common.FailKindProtocol
+	case errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return common.FailKindTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return common.FailKindRefused
+	case errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, io.ErrClosedPipe),
+		errors.Is(err, net.ErrClosed),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.EPIPE):
+		return common.FailKindClosed
+	case errors.As(err, &netErr):
+		return common.FailKindNetwork
+	default:
+		return common.FailKindOther
+	}
+}
@@ -0,0 +1,83 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/node-crawler/pkg/common"
+)
+
+func TestFailureKind(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errNoEndpoint, common.FailKindNoEndpoint},
+		{errorf("%w: %v", errDecode, "rlp: too few elements"), common.FailKindDecode},
+		{errorf("%w: %d", errInvalidCode, 99), common.FailKindProtocol},
+		{errorf("could not read from connection: %w", os.ErrDeadlineExceeded), common.FailKindTimeout},
+		{fmt.Errorf("bad hello handshake error: %w", errorf("could not read from connection: %w", io.EOF)), common.FailKindClosed},
+		{&net.OpError{Op: "dial", Err: errors.New("no route to host")}, common.FailKindNetwork},
+		{errors.New("something else"), common.FailKindOther},
+	}
+	for _, tt := range tests {
+		if got := failureKind(tt.err); got != tt.want {
+			t.Errorf("failureKind(%q) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDialRefused(t *testing.T) {
+	// Find a port nobody listens on.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	key, _ := crypto.GenerateKey()
+	var r enr.Record
+	r.Set(enr.IPv4(net.IPv4(127, 0, 0, 1)))
+	r.Set(enr.TCP(port))
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = dial(n, false, nil)
+	var dialErr *DialError
+	if !errors.As(err, &dialErr) {
+		t.Fatalf("expected dial error, got %v", err)
+	}
+	want := common.DialFailure{Phase: common.FailPhaseConnect, Kind: common.FailKindRefused}
+	if dialErr.DialFailure != want {
+		t.Fatalf("wrong failure %v, want %v", dialErr.DialFailure, want)
+	}
+}
+
+func TestReadHelloDisconnect(t *testing.T) {
+	client, server := newTestConns(t, 0)
+	go server.Write(&Disconnect{Reason: p2p.DiscTooManyPeers})
+
+	var info common.ClientInfo
+	err := readHello(client, &info)
+	var dialErr *DialError
+	if !errors.As(err, &dialErr) {
+		t.Fatalf("expected dial error, got %v", err)
+	}
+	if dialErr.Phase != common.FailPhaseHello || !dialErr.TooManyPeers() {
+		t.Fatalf("wrong failure %v", dialErr.DialFailure)
+	}
+}
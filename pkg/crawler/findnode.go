@@ -0,0 +1,340 @@
+package crawler
+
+import (
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"errors"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/discover/v4wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// enumerateBuckets is the number of buckets queried of every routing table,
+// starting at the farthest. Closer buckets are almost always empty, as the
+// chance of a node falling into them halves with every step.
+const enumerateBuckets = 16
+
+// enumerateQueueSize is the number of nodes waiting for their routing table
+// to be queried. Nodes found while the queue is full are not enumerated.
+const enumerateQueueSize = 256
+
+var (
+	enumerateRequests = metrics.NewRegisteredCounter("crawler/enumerate/requests", nil)
+	enumerateFailures = metrics.NewRegisteredCounter("crawler/enumerate/failures", nil)
+	enumerateTimer    = metrics.NewRegisteredTimer("crawler/enumerate/time", nil)
+	enumerateSkipped  = metrics.NewRegisteredCounter("crawler/enumerate/skipped", nil)
+)
+
+// neighborFinder queries the routing tables of nodes.
+type neighborFinder interface {
+	// Neighbors returns the nodes in the bucket of the routing table of n
+	// at the given log-distance from n.
+	Neighbors(n *enode.Node, distance uint) ([]*enode.Node, error)
+}
+
+// queueEnumerate queues the node for the enumeration workers, unless the
+// queue is full. Enumerating takes up to a FINDNODE timeout per bucket, so it
+// must not hold up the ENR workers.
+func (c *crawler) queueEnumerate(n *enode.Node) {
+	select {
+	case c.enumerations <- n:
+	default:
+		enumerateSkipped.Inc(1)
+		log.Debug("Enumeration queue full, skipping node", "id", n.ID())
+	}
+}
+
+// enumerateLoop enumerates the queued nodes until the queue is closed.
+func (c *crawler) enumerateLoop(wg *sync.WaitGroup) {
+	defer wg.Done()
+	for n := range c.enumerations {
+		c.enumerate(n)
+	}
+}
+
+// enumerate queries the routing table of the node, and adds it to the peer
+// graph. The query stops at the first empty bucket, or when the crawl is
+// interrupted.
+func (c *crawler) enumerate(n *enode.Node) {
+	start := time.Now()
+	defer enumerateTimer.UpdateSince(start)
+
+	for d := uint(256); d > 256-enumerateBuckets; d-- {
+		select {
+		case <-c.interrupted:
+			return
+		default:
+		}
+		enumerateRequests.Inc(1)
+		nodes, err := c.finder.Neighbors(n, d)
+		if err != nil {
+			enumerateFailures.Inc(1)
+			log.Debug("Failed to query routing table", "id", n.ID(), "distance", d, "err", err)
+			return
+		}
+		c.graph.Add(c.protocol, n.ID(), d, nodes)
+		if len(nodes) == 0 {
+			return
+		}
+	}
+}
+
+// v5Finder sends discv5 FINDNODE requests.
+type v5Finder struct {
+	*discover.UDPv5
+}
+
+func (f v5Finder) Neighbors(n *enode.Node, distance uint) ([]*enode.Node, error) {
+	return f.Findnode(n, []uint{distance})
+}
+
+// discv4 only answers FINDNODE for nodes with a recent endpoint proof, and
+// returns at most a bucket of nodes in a few packets.
+const (
+	v4BucketSize      = 16
+	v4FindnodeTimeout = time.Second
+)
+
+var (
+	errFindnodeTimeout  = errors.New("FINDNODE timeout")
+	errFindnodeConflict = errors.New("FINDNODE of the listener pending")
+	errNoUDPEndpoint    = errors.New("node has no UDP endpoint")
+)
+
+// v4ReplyPackets is the number of NEIGHBORS packets a bucket is sent in.
+const v4ReplyPackets = (v4BucketSize + v4wire.MaxNeighbors - 1) / v4wire.MaxNeighbors
+
+// v4Finder sends discv4 FINDNODE requests on the socket of the discv4
+// listener. The listener doesn't expose FINDNODE, so v4Finder wraps its
+// socket: it sends the requests itself, and takes the NEIGHBORS replies from
+// the packets read by the listener. The requests rely on the endpoint proof
+// of the ENR request which preceded them.
+//
+// NEIGHBORS packets don't name the request they answer, so the FINDNODE
+// requests of the listener are tracked as well: a node is only queried while
+// the listener has no request to it in flight, and a query is abandoned if
+// the listener sends one. Replies to a query are only taken from the address
+// it was sent to, within its timeout, and up to the packets a bucket takes.
+type v4Finder struct {
+	discover.UDPConn
+	key *ecdsa.PrivateKey
+
+	mu      sync.Mutex
+	pending map[enode.ID]*v4Query
+	// listener holds the expiry of the FINDNODE requests of the listener,
+	// by address.
+	listener map[netip.AddrPort]time.Time
+}
+
+// v4Query is a FINDNODE request in flight.
+type v4Query struct {
+	addr    netip.AddrPort
+	replies chan []v4wire.Node
+	// left is the number of NEIGHBORS packets still expected.
+	left int
+	// conflict is closed if the listener queries the node too.
+	conflict chan struct{}
+}
+
+func newV4Finder(conn discover.UDPConn, key *ecdsa.PrivateKey) *v4Finder {
+	return &v4Finder{
+		UDPConn:  conn,
+		key:      key,
+		pending:  make(map[enode.ID]*v4Query),
+		listener: make(map[netip.AddrPort]time.Time),
+	}
+}
+
+// WriteToUDPAddrPort sends a packet of the listener, recording its FINDNODE
+// requests so that their replies are left to it.
+func (f *v4Finder) WriteToUDPAddrPort(b []byte, addr netip.AddrPort) (int, error) {
+	if packetType(b) == v4wire.FindnodePacket {
+		addr := unmapAddrPort(addr)
+		now := time.Now()
+		f.mu.Lock()
+		for a, expiry := range f.listener {
+			if now.After(expiry) {
+				delete(f.listener, a)
+			}
+		}
+		f.listener[addr] = now.Add(v4FindnodeTimeout)
+		for _, q := range f.pending {
+			if q.addr == addr && q.left > 0 {
+				q.left = 0
+				close(q.conflict)
+			}
+		}
+		f.mu.Unlock()
+	}
+	return f.UDPConn.WriteToUDPAddrPort(b, addr)
+}
+
+// ReadFromUDPAddrPort reads the next packet for the listener, delivering the
+// NEIGHBORS replies to pending requests on the way.
+func (f *v4Finder) ReadFromUDPAddrPort(b []byte) (int, netip.AddrPort, error) {
+	for {
+		n, addr, err := f.UDPConn.ReadFromUDPAddrPort(b)
+		if err != nil || !f.deliver(b[:n], addr) {
+			return n, addr, err
+		}
+	}
+}
+
+// deliver hands a NEIGHBORS packet to the request waiting for it, and reports
+// whether it did. Packets the request doesn't expect are left to the listener.
+func (f *v4Finder) deliver(packet []byte, addr netip.AddrPort) bool {
+	// Checking the type avoids recovering the sender of every packet.
+	if packetType(packet) != v4wire.NeighborsPacket {
+		return false
+	}
+	f.mu.Lock()
+	waiting := len(f.pending) > 0
+	f.mu.Unlock()
+	if !waiting {
+		return false
+	}
+
+	p, from, _, err := v4wire.Decode(packet)
+	if err != nil {
+		return false
+	}
+	f.mu.Lock()
+	q, ok := f.pending[from.ID()]
+	if !ok || q.addr != unmapAddrPort(addr) || q.left == 0 {
+		f.mu.Unlock()
+		return false
+	}
+	q.left--
+	f.mu.Unlock()
+	select {
+	case q.replies <- p.(*v4wire.Neighbors).Nodes:
+	default:
+	}
+	return true
+}
+
+func (f *v4Finder) Neighbors(n *enode.Node, distance uint) ([]*enode.Node, error) {
+	addr, ok := n.UDPEndpoint()
+	if !ok {
+		return nil, errNoUDPEndpoint
+	}
+	q := &v4Query{
+		addr:     unmapAddrPort(addr),
+		replies:  make(chan []v4wire.Node, v4ReplyPackets),
+		left:     v4ReplyPackets,
+		conflict: make(chan struct{}),
+	}
+	f.mu.Lock()
+	if _, ok := f.pending[n.ID()]; ok {
+		f.mu.Unlock()
+		return nil, errors.New("FINDNODE already pending")
+	}
+	if time.Now().Before(f.listener[q.addr]) {
+		f.mu.Unlock()
+		return nil, errFindnodeConflict
+	}
+	f.pending[n.ID()] = q
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.pending, n.ID())
+		f.mu.Unlock()
+	}()
+	req := &v4wire.Findnode{
+		Target:     targetAtDistance(n.ID(), distance),
+		Expiration: uint64(time.Now().Add(20 * time.Second).Unix()),
+	}
+	packet, _, err := v4wire.Encode(f.key, req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.UDPConn.WriteToUDPAddrPort(packet, addr); err != nil {
+		return nil, err
+	}
+
+	// The reply is split in packets of MaxNeighbors nodes, a shorter packet
+	// is the last one.
+	var (
+		nodes    []*enode.Node
+		received int
+		timeout  = time.NewTimer(v4FindnodeTimeout)
+	)
+	defer timeout.Stop()
+	for received < v4BucketSize {
+		select {
+		case rn := <-q.replies:
+			received += len(rn)
+			for _, r := range rn {
+				// The reply is the closest nodes to the target, which
+				// includes other buckets if this one isn't full.
+				node, err := nodeFromV4(r)
+				if err == nil && uint(enode.LogDist(n.ID(), node.ID())) == distance {
+					nodes = append(nodes, node)
+				}
+			}
+			if len(rn) < v4wire.MaxNeighbors {
+				return nodes, nil
+			}
+		case <-q.conflict:
+			return nil, errFindnodeConflict
+		case <-timeout.C:
+			if received == 0 {
+				return nil, errFindnodeTimeout
+			}
+			return nodes, nil
+		}
+	}
+	return nodes, nil
+}
+
+// packetType returns the type of a discv4 packet, which follows its hash and
+// signature, or zero if the packet is too short.
+func packetType(packet []byte) byte {
+	const typeOffset = 32 + 65
+	if len(packet) <= typeOffset {
+		return 0
+	}
+	return packet[typeOffset]
+}
+
+// unmapAddrPort returns the address with IPv4-mapped IPv6 addresses turned
+// into IPv4 ones, as dual-stack sockets report them.
+func unmapAddrPort(addr netip.AddrPort) netip.AddrPort {
+	return netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+}
+
+// targetAtDistance returns a FINDNODE target whose hash is at the given
+// log-distance from id, so the reply is the bucket at that distance. The
+// target is searched by brute force, which takes about 2^(256-distance)
+// hashes.
+func targetAtDistance(id enode.ID, distance uint) v4wire.Pubkey {
+	var target v4wire.Pubkey
+	crand.Read(target[:])
+	for {
+		if uint(enode.LogDist(id, enode.ID(crypto.Keccak256Hash(target[:])))) == distance {
+			return target
+		}
+		// Incrementing a counter is much cheaper than new random bytes.
+		for i := len(target) - 1; i >= 0; i-- {
+			target[i]++
+			if target[i] != 0 {
+				break
+			}
+		}
+	}
+}
+
+func nodeFromV4(r v4wire.Node) (*enode.Node, error) {
+	key, err := v4wire.DecodePubkey(crypto.S256(), r.ID)
+	if err != nil {
+		return nil, err
+	}
+	return enode.NewV4(key, r.IP, int(r.TCP), int(r.UDP)), nil
+}
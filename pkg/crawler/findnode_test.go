@@ -0,0 +1,236 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discover/v4wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/node-crawler/pkg/simnet"
+)
+
+func TestV4Finder(t *testing.T) {
+	remoteKey, _ := crypto.GenerateKey()
+	remote, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	remoteAddr := remote.LocalAddr().(*net.UDPAddr)
+	remoteNode := enode.NewV4(&remoteKey.PublicKey, remoteAddr.IP, 0, remoteAddr.Port)
+
+	// The table of the remote, half of it is in the farthest bucket.
+	var table []v4wire.Node
+	want := make(map[enode.ID]bool)
+	for len(table) < v4BucketSize {
+		key, _ := crypto.GenerateKey()
+		n := v4wire.Node{IP: net.IPv4(10, 0, 0, byte(len(table))), UDP: 30303, TCP: 30303, ID: v4wire.EncodePubkey(&key.PublicKey)}
+		table = append(table, n)
+		if enode.LogDist(remoteNode.ID(), n.ID.ID()) == 256 {
+			want[n.ID.ID()] = true
+		}
+	}
+
+	// The remote answers FINDNODE like geth, in two packets.
+	go func() {
+		buf := make([]byte, 1280)
+		for {
+			n, from, err := remote.ReadFromUDPAddrPort(buf)
+			if err != nil {
+				return
+			}
+			p, _, _, err := v4wire.Decode(buf[:n])
+			if err != nil || p.Kind() != v4wire.FindnodePacket {
+				continue
+			}
+			exp := uint64(time.Now().Add(20 * time.Second).Unix())
+			for _, nodes := range [][]v4wire.Node{table[:v4wire.MaxNeighbors], table[v4wire.MaxNeighbors:]} {
+				packet, _, _ := v4wire.Encode(remoteKey, &v4wire.Neighbors{Nodes: nodes, Expiration: exp})
+				remote.WriteToUDPAddrPort(packet, from)
+			}
+		}
+	}()
+
+	localKey, _ := crypto.GenerateKey()
+	local, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+	f := newV4Finder(local, localKey)
+	// The listener reads the socket.
+	go func() {
+		buf := make([]byte, 1280)
+		for {
+			if _, _, err := f.ReadFromUDPAddrPort(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	nodes, err := f.Neighbors(remoteNode, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != len(want) {
+		t.Fatalf("got %d nodes, want %d", len(nodes), len(want))
+	}
+	for _, n := range nodes {
+		if !want[n.ID()] {
+			t.Errorf("node %v not in the bucket", n.ID())
+		}
+	}
+}
+
+func TestV4FinderListenerQueries(t *testing.T) {
+	remoteKey, _ := crypto.GenerateKey()
+	remote, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	remoteAddr := remote.LocalAddr().(*net.UDPAddr)
+	remoteNode := enode.NewV4(&remoteKey.PublicKey, remoteAddr.IP, 0, remoteAddr.Port)
+
+	// The remote only answers once the test says so.
+	answer := make(chan struct{})
+	go func() {
+		buf := make([]byte, 1280)
+		for {
+			n, from, err := remote.ReadFromUDPAddrPort(buf)
+			if err != nil {
+				return
+			}
+			p, _, _, err := v4wire.Decode(buf[:n])
+			if err != nil || p.Kind() != v4wire.FindnodePacket {
+				continue
+			}
+			<-answer
+			exp := uint64(time.Now().Add(20 * time.Second).Unix())
+			packet, _, _ := v4wire.Encode(remoteKey, &v4wire.Neighbors{Expiration: exp})
+			remote.WriteToUDPAddrPort(packet, from)
+		}
+	}()
+
+	localKey, _ := crypto.GenerateKey()
+	local, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+	f := newV4Finder(local, localKey)
+	// The listener reads the socket, and reports the NEIGHBORS packets left
+	// to it.
+	neighbors := make(chan struct{}, 8)
+	go func() {
+		buf := make([]byte, 1280)
+		for {
+			n, _, err := f.ReadFromUDPAddrPort(buf)
+			if err != nil {
+				return
+			}
+			if packetType(buf[:n]) == v4wire.NeighborsPacket {
+				neighbors <- struct{}{}
+			}
+		}
+	}()
+	listenerFindnode := func() {
+		exp := uint64(time.Now().Add(20 * time.Second).Unix())
+		packet, _, _ := v4wire.Encode(localKey, &v4wire.Findnode{Expiration: exp})
+		if _, err := f.WriteToUDPAddrPort(packet, remoteAddr.AddrPort()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A query sent while the listener waits for a reply of the node is
+	// refused.
+	listenerFindnode()
+	if _, err := f.Neighbors(remoteNode, 256); !errors.Is(err, errFindnodeConflict) {
+		t.Fatalf("query during listener FINDNODE: got err %v, want %v", err, errFindnodeConflict)
+	}
+	answer <- struct{}{}
+	select {
+	case <-neighbors:
+	case <-time.After(time.Second):
+		t.Fatal("reply not left to the listener")
+	}
+
+	// A query is abandoned if the listener sends its own, whose reply is
+	// left to the listener.
+	f.mu.Lock()
+	clear(f.listener)
+	f.mu.Unlock()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := f.Neighbors(remoteNode, 256)
+		errc <- err
+	}()
+	for {
+		f.mu.Lock()
+		_, pending := f.pending[remoteNode.ID()]
+		f.mu.Unlock()
+		if pending {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	listenerFindnode()
+	if err := <-errc; !errors.Is(err, errFindnodeConflict) {
+		t.Fatalf("query abandoned for listener FINDNODE: got err %v, want %v", err, errFindnodeConflict)
+	}
+	answer <- struct{}{}
+	answer <- struct{}{}
+	for range 2 {
+		select {
+		case <-neighbors:
+		case <-time.After(time.Second):
+			t.Fatal("reply not left to the listener")
+		}
+	}
+}
+
+func TestTargetAtDistance(t *testing.T) {
+	var id enode.ID
+	for _, d := range []uint{256, 250, 245} {
+		target := targetAtDistance(id, d)
+		if got := enode.LogDist(id, enode.ID(crypto.Keccak256Hash(target[:]))); uint(got) != d {
+			t.Errorf("target for distance %d at distance %d", d, got)
+		}
+	}
+}
+
+func TestRunEnumerate(t *testing.T) {
+	nw := simnet.New(simnet.Config{
+		Nodes:         300,
+		Seed:          8,
+		Degree:        64,
+		OfflineRatio:  0.1,
+		IteratorLimit: 1000,
+	})
+
+	c := newTestCrawler(nw, nil)
+	c.finder = nw
+	output := c.Run(context.Background(), time.Minute)
+
+	enumerated, edges := c.graph.Len()
+	// The crawl ends with the iterators, so the queued nodes are all
+	// enumerated.
+	if enumerated == 0 || enumerated > len(output) {
+		t.Errorf("enumerated %d nodes of %d", enumerated, len(output))
+	}
+	if edges == 0 {
+		t.Fatal("no edges")
+	}
+	for _, e := range c.graph.Edges() {
+		if _, ok := output[e.From]; !ok {
+			t.Errorf("edge from node %v not in output", e.From)
+		}
+		if d := uint(enode.LogDist(e.From, e.To)); d != e.Distance {
+			t.Errorf("edge at distance %d recorded at %d", d, e.Distance)
+		}
+	}
+}
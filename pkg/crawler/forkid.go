@@ -0,0 +1,158 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+
+	gethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/node-crawler/pkg/common"
+	"github.com/ethereum/node-crawler/pkg/enrattr"
+	"github.com/ethereum/node-crawler/pkg/networks"
+)
+
+var (
+	foreignSkipped = metrics.NewRegisteredCounter("crawler/dial/foreign/skipped", nil)
+	foreignRound   = metrics.NewRegisteredGauge("crawler/round/foreign", nil)
+)
+
+// forkChecker classifies the fork IDs of nodes against the local view of the
+// crawled network.
+type forkChecker struct {
+	chain  forkid.Blockchain
+	filter forkid.Filter
+	// index maps the fork hashes of the chain to their position in the
+	// fork sequence. They are derived from the chain config like those the
+	// filter accepts, the fork IDs of the profile may be incomplete.
+	index map[[4]byte]int
+	// chains attributes the other fork hashes to the known chains, it may be
+	// nil. own is the name of our chain in it.
+	chains *networks.Registry
+	own    string
+}
+
+func newForkChecker(network *networks.Profile, chain forkid.Blockchain, chains *networks.Registry) *forkChecker {
+	ids := networks.ForkIDs(chain.Config(), chain.Genesis())
+	index := make(map[[4]byte]int, len(ids))
+	for i, id := range ids {
+		index[id.Hash] = i
+	}
+	fc := &forkChecker{
+		chain:  chain,
+		filter: forkid.NewFilter(chain),
+		index:  index,
+		chains: chains,
+		own:    networks.UnknownChain,
+	}
+	if chains != nil {
+		fc.own = chains.Classify(forkid.ID{}, network.GenesisHash, network.NetworkID).Chain
+	}
+	return fc
+}
+
+// classify returns the compatibility of the remote fork ID with our chain.
+func (fc *forkChecker) classify(remote forkid.ID) string {
+	err := fc.filter(remote)
+	switch {
+	case errors.Is(err, forkid.ErrRemoteStale):
+		return common.ForkStale
+	case err != nil:
+		return common.ForkIncompatible
+	}
+
+	local := forkid.NewIDWithChain(fc.chain)
+	if remote.Hash != local.Hash {
+		// The filter accepted a different fork hash, so the node is either
+		// still syncing, or has passed forks we did not reach yet. Without
+		// the position of both hashes, we can't tell which.
+		remoteAt, remoteOK := fc.index[remote.Hash]
+		localAt, localOK := fc.index[local.Hash]
+		if remoteOK && localOK && remoteAt > localAt {
+			return common.ForkFuture
+		}
+		return common.ForkCompatible
+	}
+
+	switch {
+	case remote.Next == local.Next:
+		return common.ForkCompatible
+	case local.Next == 0:
+		// The node schedules a fork we do not know about.
+		return common.ForkFuture
+	case remote.Next == 0 || remote.Next > local.Next:
+		// The node is missing our upcoming fork.
+		return common.ForkStale
+	default:
+		// The node schedules a different fork before our next one.
+		return common.ForkIncompatible
+	}
+}
+
+// foreign reports whether the fork ID is of another network. The fork hashes
+// of our chain are not foreign, whether or not the node is compatible with
+// us, like stale nodes. Other hashes are foreign, unless the registry knows
+// them as hashes of our chain, like those of forks our config doesn't have
+// yet.
+func (fc *forkChecker) foreign(remote forkid.ID) bool {
+	if _, ok := fc.index[remote.Hash]; ok {
+		return false
+	}
+	if fc.chains == nil || fc.own == networks.UnknownChain {
+		return true
+	}
+	return fc.chains.Classify(remote, gethCommon.Hash{}, 0).Chain != fc.own
+}
+
+// foreignNetwork reports whether the record of the node announces the fork ID
+// of another network. Nodes without an "eth" entry are not foreign.
+func (c *crawler) foreignNetwork(n *enode.Node) bool {
+	if c.forks == nil {
+		return false
+	}
+	var eth enrattr.Eth
+	if n.Load(&eth) != nil {
+		return false
+	}
+	return c.forks.foreign(eth.ForkID)
+}
+
+// ForkFilter is the policy for dialing nodes whose record announces the fork
+// ID of another network in its "eth" entry.
+type ForkFilter int
+
+const (
+	// ForkFilterOff dials all nodes.
+	ForkFilterOff ForkFilter = iota
+	// ForkFilterSkip doesn't dial nodes of other networks.
+	ForkFilterSkip
+	// ForkFilterDeprioritize dials nodes of other networks after all others.
+	ForkFilterDeprioritize
+)
+
+func (f ForkFilter) String() string {
+	switch f {
+	case ForkFilterSkip:
+		return "skip"
+	case ForkFilterDeprioritize:
+		return "deprioritize"
+	default:
+		return "off"
+	}
+}
+
+// ParseForkFilter returns the policy of the given name, "off", "skip" or
+// "deprioritize".
+func ParseForkFilter(name string) (ForkFilter, error) {
+	switch name {
+	case "off", "":
+		return ForkFilterOff, nil
+	case "skip":
+		return ForkFilterSkip, nil
+	case "deprioritize":
+		return ForkFilterDeprioritize, nil
+	default:
+		return ForkFilterOff, fmt.Errorf("unknown fork filter %q", name)
+	}
+}
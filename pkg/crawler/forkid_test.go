@@ -0,0 +1,156 @@
+package crawler
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/node-crawler/pkg/common"
+	"github.com/ethereum/node-crawler/pkg/networks"
+)
+
+func TestForkCheckerClassify(t *testing.T) {
+	mainnet, err := networks.Lookup("mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend our head is in Cancun.
+	head := &types.Header{Number: big.NewInt(20000000), Time: 1710338135 + 100}
+	chain := newChainStatus(mainnet.Genesis, mainnet.NetworkID, NewStaticStatus(head))
+
+	var (
+		shanghai = forkid.ID{Hash: [4]byte{0xdc, 0xe9, 0x6c, 0x2d}, Next: 1710338135}
+		cancun   = forkid.ID{Hash: [4]byte{0x9f, 0x3d, 0x22, 0x54}, Next: 1746612311}
+		prague   = mainnet.ForkIDs[15]
+	)
+	tests := []struct {
+		name   string
+		remote forkid.ID
+		want   string
+	}{
+		{"same", cancun, common.ForkCompatible},
+		{"syncing", shanghai, common.ForkCompatible},
+		{"syncing-unaware", forkid.ID{Hash: shanghai.Hash}, common.ForkStale},
+		{"missing-next", forkid.ID{Hash: cancun.Hash}, common.ForkStale},
+		{"later-next", forkid.ID{Hash: cancun.Hash, Next: cancun.Next + 1}, common.ForkStale},
+		{"earlier-next", forkid.ID{Hash: cancun.Hash, Next: cancun.Next - 1}, common.ForkIncompatible},
+		{"ahead", prague, common.ForkFuture},
+		{"other-chain", forkid.ID{Hash: [4]byte{0xde, 0xad, 0xbe, 0xef}}, common.ForkIncompatible},
+	}
+
+	fc := newForkChecker(mainnet, chain, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fc.classify(tt.remote); got != tt.want {
+				t.Errorf("classify(%v) = %s, want %s", tt.remote, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForkCheckerPartialProfile(t *testing.T) {
+	mainnet, err := networks.Lookup("mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A profile whose fork IDs stop before our head, as a custom genesis
+	// profile might.
+	partial := *mainnet
+	partial.ForkIDs = partial.ForkIDs[:1]
+
+	head := &types.Header{Number: big.NewInt(20000000), Time: 1710338135 + 100}
+	chain := newChainStatus(partial.Genesis, partial.NetworkID, NewStaticStatus(head))
+	fc := newForkChecker(&partial, chain, nil)
+
+	tests := []struct {
+		name   string
+		remote forkid.ID
+		want   string
+	}{
+		{"syncing", mainnet.ForkIDs[13], common.ForkCompatible},
+		{"same", mainnet.ForkIDs[14], common.ForkCompatible},
+		{"ahead", mainnet.ForkIDs[15], common.ForkFuture},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fc.classify(tt.remote); got != tt.want {
+				t.Errorf("classify(%v) = %s, want %s", tt.remote, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForkCheckerForeign(t *testing.T) {
+	mainnet, err := networks.Lookup("mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sepolia, err := networks.Lookup("sepolia")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The registry knows a mainnet fork our config doesn't have yet.
+	future := [4]byte{0x01, 0x02, 0x03, 0x04}
+	file := filepath.Join(t.TempDir(), "chains.json")
+	chains := fmt.Sprintf(`[{"name": "mainnet", "networkId": 1, "genesisHash": "%v", "forks": [{"name": "Future", "hash": "0x01020304"}]}]`, mainnet.GenesisHash)
+	if err := os.WriteFile(file, []byte(chains), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := networks.NewRegistry(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bsc networks.Chain
+	for _, c := range registry.Chains() {
+		if c.Name == "bsc" {
+			bsc = c
+		}
+	}
+	if len(bsc.Forks) == 0 {
+		t.Fatal("bsc is not a known chain")
+	}
+
+	chain := newChainStatus(mainnet.Genesis, mainnet.NetworkID, nil)
+	for _, r := range []*networks.Registry{nil, registry} {
+		fc := newForkChecker(mainnet, chain, r)
+		for i, id := range mainnet.ForkIDs {
+			if fc.foreign(id) {
+				t.Errorf("mainnet fork ID %d (%v) is foreign", i, id)
+			}
+		}
+		// Stale nodes of the network are not foreign either.
+		if fc.foreign(forkid.ID{Hash: mainnet.ForkIDs[3].Hash}) {
+			t.Error("stale fork ID is foreign")
+		}
+		if id := sepolia.ForkIDs[len(sepolia.ForkIDs)-1]; !fc.foreign(id) {
+			t.Errorf("sepolia fork ID %v is not foreign", id)
+		}
+		if id := (forkid.ID{Hash: [4]byte(bsc.Forks[len(bsc.Forks)-1].Hash)}); !fc.foreign(id) {
+			t.Errorf("bsc fork ID %v is not foreign", id)
+		}
+		if id := (forkid.ID{Hash: [4]byte{0xde, 0xad, 0xbe, 0xef}}); !fc.foreign(id) {
+			t.Errorf("unknown fork ID %v is not foreign", id)
+		}
+		if got, want := fc.foreign(forkid.ID{Hash: future}), r == nil; got != want {
+			t.Errorf("future mainnet fork ID foreign: %t, want %t", got, want)
+		}
+	}
+}
+
+func TestParseForkFilter(t *testing.T) {
+	for _, f := range []ForkFilter{ForkFilterOff, ForkFilterSkip, ForkFilterDeprioritize} {
+		got, err := ParseForkFilter(f.String())
+		if err != nil || got != f {
+			t.Errorf("ParseForkFilter(%q) = %v, %v", f.String(), got, err)
+		}
+	}
+	if _, err := ParseForkFilter("drop"); err == nil {
+		t.Error("no error for unknown filter")
+	}
+}
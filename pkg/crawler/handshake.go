@@ -3,48 +3,57 @@ package crawler
 import (
 	"context"
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
-	"math/big"
+	"math/rand"
 	"net"
+	"net/netip"
 	"time"
 
-	"github.com/ethereum/go-ethereum/core"
-	"github.com/ethereum/go-ethereum/core/forkid"
 	ethTypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/rlpx"
-	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/node-crawler/pkg/common"
 )
 
-var (
-	_status          *Status
-	lastStatusUpdate time.Time
-)
-
-func getClientInfo(genesis *core.Genesis, networkID uint64, nodeURL string, n *enode.Node) (*common.ClientInfo, error) {
+// getClientInfo dials the node and reads its client info. On error, the info
+// holds what was read before the failure, and the timings of the phases
+// which were completed.
+func getClientInfo(
+	status *chainStatus,
+	n *enode.Node,
+	cfg handshakeConfig,
+) (*common.ClientInfo, error) {
 	var info common.ClientInfo
 
-	conn, sk, err := dial(n)
+	conn, sk, err := dial(n, cfg.dialFallback, cfg.key)
+	if conn != nil {
+		info.DialAddr = conn.addr
+		info.Timings.Connect = conn.connectTime
+		info.Timings.Handshake = conn.handshakeTime
+	}
 	if err != nil {
-		return nil, err
+		return &info, err
 	}
 	defer conn.Close()
 
 	if err = conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
-		return nil, fmt.Errorf("cannot set conn deadline: %w", err)
+		return &info, newDialError(common.FailPhaseHello, fmt.Errorf("cannot set conn deadline: %w", err))
 	}
 
-	if err = writeHello(conn, sk); err != nil {
-		return nil, err
+	start := time.Now()
+	if err = writeHello(conn, sk, cfg.name, cfg.listenPort); err != nil {
+		return &info, newDialError(common.FailPhaseHello, err)
 	}
 	if err = readHello(conn, &info); err != nil {
-		return nil, err
+		return &info, err
 	}
+	info.Timings.Hello = time.Since(start)
 
 	// If node provides no eth version, we can skip it.
 	if conn.negotiatedProtoVersion == 0 {
@@ -52,19 +61,38 @@ func getClientInfo(genesis *core.Genesis, networkID uint64, nodeURL string, n *e
 	}
 
 	if err = conn.SetDeadline(time.Now().Add(15 * time.Second)); err != nil {
-		return nil, fmt.Errorf("cannot set conn deadline: %w", err)
+		return &info, newDialError(common.FailPhaseStatus, fmt.Errorf("cannot set conn deadline: %w", err))
 	}
 
-	s := getStatus(genesis.Config, uint32(conn.negotiatedProtoVersion), genesis.ToBlock(), networkID, nodeURL)
+	ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+	s, err := status.message(ctx, uint32(conn.negotiatedProtoVersion))
+	cancel()
+	if err != nil {
+		return &info, newDialErrorKind(common.FailPhaseStatus, common.FailKindOther, fmt.Errorf("cannot create status: %w", err))
+	}
+	start = time.Now()
 	if err = conn.Write(s); err != nil {
-		return nil, err
+		return &info, newDialError(common.FailPhaseStatus, err)
 	}
 
 	// Regardless of whether we wrote a status message or not, the remote side
 	// might still send us one.
 
 	if err = readStatus(conn, &info); err != nil {
-		return nil, err
+		return &info, err
+	}
+	info.Timings.Status = time.Since(start)
+	status.observeTD(info.TotalDifficulty)
+
+	// Failing to get the head header is not fatal, we still have the status.
+	head, err := readHeadHeader(conn, &info)
+	if err != nil {
+		log.Debug("Could not get head header", "id", n.ID(), "err", err)
+	}
+
+	// A node whose connection was lost won't answer the probe.
+	if cfg.snapProbe && conn.negotiatedSnapProtoVersion > 0 && !errors.Is(err, errConnLost) {
+		info.Snap = probeSnap(conn, head)
 	}
 
 	// Disconnect from client
@@ -73,49 +101,131 @@ func getClientInfo(genesis *core.Genesis, networkID uint64, nodeURL string, n *e
 	return &info, nil
 }
 
+// handshakeConfig contains the settings for connecting to nodes.
+type handshakeConfig struct {
+	// key is our RLPx identity. A new key is generated for every
+	// connection if it is nil.
+	key *ecdsa.PrivateKey
+	// name and listenPort are announced in our Hello message.
+	name         string
+	listenPort   uint64
+	dialFallback bool
+	snapProbe    bool
+}
+
 // dial attempts to dial the given node and perform a handshake,
-func dial(n *enode.Node) (*Conn, *ecdsa.PrivateKey, error) {
+// If fallback is set and the node's preferred endpoint cannot be reached,
+// the endpoint of the other address family is tried as well.
+// The handshake uses ourKey, or a new key if it is nil. If the handshake
+// fails, the closed connection is returned with the error, for its address
+// and connect time.
+func dial(n *enode.Node, fallback bool, ourKey *ecdsa.PrivateKey) (*Conn, *ecdsa.PrivateKey, error) {
 	var conn Conn
 
+	endpoints := tcpEndpoints(n)
+	if len(endpoints) == 0 {
+		return nil, nil, newDialError(common.FailPhaseConnect, errNoEndpoint)
+	}
+	if !fallback {
+		endpoints = endpoints[:1]
+	}
+
 	// dial
+	var (
+		fd   net.Conn
+		err  error
+		errs []error
+	)
 	dialer := net.Dialer{Timeout: 10 * time.Second}
-	fd, err := dialer.Dial("tcp", fmt.Sprintf("%v:%d", n.IP(), n.TCP()))
-	if err != nil {
-		return nil, nil, err
+	start := time.Now()
+	for _, addr := range endpoints {
+		fd, err = dialer.Dial("tcp", addr.String())
+		if err == nil {
+			conn.addr = addr
+			break
+		}
+		errs = append(errs, err)
+	}
+	if fd == nil {
+		return nil, nil, newDialError(common.FailPhaseConnect, errors.Join(errs...))
 	}
+	conn.connectTime = time.Since(start)
 
 	conn.Conn = rlpx.NewConn(fd, n.Pubkey())
 
 	if err = conn.SetDeadline(time.Now().Add(15 * time.Second)); err != nil {
-		return nil, nil, fmt.Errorf("cannot set conn deadline: %w", err)
+		conn.Close()
+		return nil, nil, newDialError(common.FailPhaseHandshake, fmt.Errorf("cannot set conn deadline: %w", err))
 	}
 
 	// do encHandshake
-	ourKey, _ := crypto.GenerateKey()
+	if ourKey == nil {
+		ourKey, _ = crypto.GenerateKey()
+	}
 
+	start = time.Now()
 	_, err = conn.Handshake(ourKey)
 	if err != nil {
-		return nil, nil, err
+		conn.Close()
+		return &conn, nil, newDialError(common.FailPhaseHandshake, err)
 	}
+	conn.handshakeTime = time.Since(start)
 
 	return &conn, ourKey, nil
 }
 
-func writeHello(conn *Conn, priv *ecdsa.PrivateKey) error {
+// tcpEndpoints returns the TCP endpoints of the node, in order of preference.
+// The first one is the endpoint chosen by enode, the second one, if the record
+// has both an IPv4 and IPv6 address, is the endpoint of the other family.
+func tcpEndpoints(n *enode.Node) []netip.AddrPort {
+	preferred, ok := n.TCPEndpoint()
+	if !ok {
+		return nil
+	}
+	endpoints := []netip.AddrPort{preferred}
+
+	var (
+		ip   netip.Addr
+		port uint16
+	)
+	if preferred.Addr().Is4() {
+		if n.Load((*enr.IPv6Addr)(&ip)) != nil {
+			return endpoints
+		}
+		if n.Load((*enr.TCP6)(&port)) != nil {
+			n.Load((*enr.TCP)(&port))
+		}
+	} else {
+		if n.Load((*enr.IPv4Addr)(&ip)) != nil {
+			return endpoints
+		}
+		n.Load((*enr.TCP)(&port))
+	}
+	if !ip.IsValid() || ip.IsUnspecified() || port == 0 {
+		return endpoints
+	}
+
+	return append(endpoints, netip.AddrPortFrom(ip, port))
+}
+
+func writeHello(conn *Conn, priv *ecdsa.PrivateKey, name string, listenPort uint64) error {
 	pub0 := crypto.FromECDSAPub(&priv.PublicKey)[1:]
 
 	h := &Hello{
-		Version: 5,
+		Version:    5,
+		Name:       name,
+		ListenPort: listenPort,
 		Caps: []p2p.Cap{
 			{Name: "eth", Version: 66},
 			{Name: "eth", Version: 67},
 			{Name: "eth", Version: 68},
+			{Name: "eth", Version: 69},
 			{Name: "snap", Version: 1},
 		},
 		ID: pub0,
 	}
 
-	conn.ourHighestProtoVersion = 68
+	conn.ourHighestProtoVersion = 69
 	conn.ourHighestSnapProtoVersion = 1
 
 	return conn.Write(h)
@@ -136,64 +246,116 @@ func readHello(conn *Conn, info *common.ClientInfo) error {
 
 		return nil
 	case *Disconnect:
-		return fmt.Errorf("bad hello handshake disconnect: %v", msg.Reason.Error())
+		return newDisconnectError(common.FailPhaseHello, msg.Reason,
+			fmt.Errorf("bad hello handshake disconnect: %v", msg.Reason.Error()))
 	case *Error:
-		return fmt.Errorf("bad hello handshake error: %v", msg.Error())
+		return newDialError(common.FailPhaseHello, fmt.Errorf("bad hello handshake error: %w", msg))
 	default:
-		return fmt.Errorf("bad hello handshake code: %v", msg.Code())
+		return newDialErrorKind(common.FailPhaseHello, common.FailKindProtocol,
+			fmt.Errorf("bad hello handshake code: %v", msg.Code()))
 	}
 }
 
-func getStatus(config *params.ChainConfig, version uint32, genesis *ethTypes.Block, network uint64, nodeURL string) *Status {
-	if _status == nil {
-		_status = &Status{
-			ProtocolVersion: version,
-			NetworkID:       network,
-			TD:              big.NewInt(0),
-			Head:            genesis.Hash(),
-			Genesis:         genesis.Hash(),
-			ForkID:          forkid.NewID(config, genesis, 0, 0),
+func readStatus(conn *Conn, info *common.ClientInfo) error {
+	for {
+		switch msg := conn.Read().(type) {
+		case *Status69:
+			info.ForkID = msg.ForkID
+			info.HeadHash = msg.LatestBlockHash
+			info.NetworkID = msg.NetworkID
+			info.Genesis = msg.Genesis
+			info.EarliestBlock = msg.EarliestBlock
+			info.LatestBlock = msg.LatestBlock
+		case *Status:
+			info.ForkID = msg.ForkID
+			info.HeadHash = msg.Head
+			info.NetworkID = msg.NetworkID
+			info.Genesis = msg.Genesis
+			// m.ProtocolVersion
+			info.TotalDifficulty = msg.TD
+		case *Ping:
+			if err := conn.Write(Pong{}); err != nil {
+				return newDialError(common.FailPhaseStatus, err)
+			}
+			continue
+		case *BlockRangeUpdate:
+			handleBlockRangeUpdate(msg, info)
+			continue
+		case *Disconnect:
+			return newDisconnectError(common.FailPhaseStatus, msg.Reason,
+				fmt.Errorf("bad status handshake disconnect: %v", msg.Reason.Error()))
+		case *Error:
+			return newDialError(common.FailPhaseStatus, fmt.Errorf("bad status handshake error: %w", msg))
+		default:
+			return newDialErrorKind(common.FailPhaseStatus, common.FailKindProtocol,
+				fmt.Errorf("bad status handshake code: %v", msg.Code()))
 		}
+		return nil
 	}
+}
 
-	if nodeURL != "" && time.Since(lastStatusUpdate) > 15*time.Second {
-		cl, err := ethclient.Dial(nodeURL)
-		if err != nil {
-			log.Error("ethclient.Dial", "err", err)
-			return _status
-		}
-
-		header, err := cl.HeaderByNumber(context.Background(), nil)
-		if err != nil {
-			log.Error("cannot get header by number", "err", err)
-			return _status
-		}
+// handleBlockRangeUpdate records a change of the block range an eth/69 node
+// can serve, which it may send at any time after the status exchange.
+func handleBlockRangeUpdate(msg *BlockRangeUpdate, info *common.ClientInfo) {
+	info.EarliestBlock = msg.EarliestBlock
+	info.LatestBlock = msg.LatestBlock
+	info.HeadHash = msg.LatestBlockHash
+}
 
-		_status.Head = header.Hash()
-		_status.ForkID = forkid.NewID(config, genesis, header.Number.Uint64(), header.Time)
+// readHeadHeader requests the header of the node's head block, and records its
+// number and timestamp. Errors after which the connection can't be used
+// anymore wrap errConnLost.
+func readHeadHeader(conn *Conn, info *common.ClientInfo) (*ethTypes.Header, error) {
+	head := info.HeadHash
+	reqID := rand.Uint64()
+	req := &GetBlockHeaders{
+		RequestId: reqID,
+		GetBlockHeadersRequest: &eth.GetBlockHeadersRequest{
+			Origin: eth.HashOrNumber{Hash: head},
+			Amount: 1,
+		},
+	}
+	if err := conn.Write(req); err != nil {
+		return nil, fmt.Errorf("%w: %w", errConnLost, err)
 	}
 
-	return _status
-}
-
-func readStatus(conn *Conn, info *common.ClientInfo) error {
-	switch msg := conn.Read().(type) {
-	case *Status:
-		info.ForkID = msg.ForkID
-		info.HeadHash = msg.Head
-		info.NetworkID = msg.NetworkID
-		// m.ProtocolVersion
-		info.TotalDifficulty = msg.TD
-		// Set correct TD if received TD is higher
-		if msg.TD.Cmp(_status.TD) > 0 {
-			_status.TD = msg.TD
+	for {
+		switch msg := conn.Read().(type) {
+		case *BlockHeaders:
+			if msg.RequestId != reqID {
+				continue
+			}
+			if len(msg.BlockHeadersRequest) == 0 {
+				return nil, errors.New("head header not found")
+			}
+			header := msg.BlockHeadersRequest[0]
+			if header.Hash() != head {
+				return nil, fmt.Errorf("wrong head header %v", header.Hash())
+			}
+			info.Blockheight = header.Number.String()
+			info.HeadTime = header.Time
+			return header, nil
+		case *Ping:
+			if err := conn.Write(Pong{}); err != nil {
+				return nil, fmt.Errorf("%w: %w", errConnLost, err)
+			}
+		case *BlockRangeUpdate:
+			// The header describes the requested head, which stays the
+			// one we record.
+			info.EarliestBlock = msg.EarliestBlock
+			info.LatestBlock = msg.LatestBlock
+		case *Disconnect:
+			return nil, fmt.Errorf("bad head header: %w: %v", errConnLost, msg.Reason.Error())
+		case *Error:
+			// Only messages which can't be decoded leave the connection
+			// usable, read errors and timeouts don't.
+			switch failureKind(msg) {
+			case common.FailKindDecode, common.FailKindProtocol:
+				return nil, fmt.Errorf("bad head header error: %w", msg)
+			}
+			return nil, fmt.Errorf("bad head header error: %w: %w", errConnLost, msg)
+		default:
+			// Ignore announcements and requests sent by the node.
 		}
-	case *Disconnect:
-		return fmt.Errorf("bad status handshake disconnect: %v", msg.Reason.Error())
-	case *Error:
-		return fmt.Errorf("bad status handshake error: %v", msg.Error())
-	default:
-		return fmt.Errorf("bad status handshake code: %v", msg.Code())
 	}
-	return nil
 }
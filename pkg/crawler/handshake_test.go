@@ -0,0 +1,261 @@
+package crawler
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/rlpx"
+	"github.com/ethereum/node-crawler/pkg/common"
+)
+
+func TestTCPEndpoints(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	newNode := func(entries ...enr.Entry) *enode.Node {
+		var r enr.Record
+		for _, e := range entries {
+			r.Set(e)
+		}
+		if err := enode.SignV4(&r, key); err != nil {
+			t.Fatal(err)
+		}
+		n, err := enode.New(enode.ValidSchemes, &r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	tests := []struct {
+		name string
+		node *enode.Node
+		want []netip.AddrPort
+	}{
+		{
+			name: "no-tcp",
+			node: newNode(enr.IPv4(net.ParseIP("1.2.3.4")), enr.UDP(30303)),
+		},
+		{
+			name: "ipv4",
+			node: newNode(enr.IPv4(net.ParseIP("1.2.3.4")), enr.TCP(30303)),
+			want: []netip.AddrPort{netip.MustParseAddrPort("1.2.3.4:30303")},
+		},
+		{
+			name: "ipv6",
+			node: newNode(enr.IPv6(net.ParseIP("2001:db8::1")), enr.TCP6(30304)),
+			want: []netip.AddrPort{netip.MustParseAddrPort("[2001:db8::1]:30304")},
+		},
+		{
+			name: "dual-stack",
+			node: newNode(
+				enr.IPv4(net.ParseIP("1.2.3.4")),
+				enr.TCP(30303),
+				enr.IPv6(net.ParseIP("2001:db8::1")),
+				enr.TCP6(30304),
+			),
+			want: []netip.AddrPort{
+				netip.MustParseAddrPort("1.2.3.4:30303"),
+				netip.MustParseAddrPort("[2001:db8::1]:30304"),
+			},
+		},
+		{
+			name: "dual-stack-shared-port",
+			node: newNode(
+				enr.IPv4(net.ParseIP("1.2.3.4")),
+				enr.IPv6(net.ParseIP("2001:db8::1")),
+				enr.TCP(30303),
+			),
+			want: []netip.AddrPort{
+				netip.MustParseAddrPort("1.2.3.4:30303"),
+				netip.MustParseAddrPort("[2001:db8::1]:30303"),
+			},
+		},
+		{
+			name: "private-ipv4-global-ipv6",
+			node: newNode(
+				enr.IPv4(net.ParseIP("192.168.1.1")),
+				enr.TCP(30303),
+				enr.IPv6(net.ParseIP("2001:db8::1")),
+				enr.TCP6(30304),
+			),
+			want: []netip.AddrPort{
+				netip.MustParseAddrPort("[2001:db8::1]:30304"),
+				netip.MustParseAddrPort("192.168.1.1:30303"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tcpEndpoints(tt.node); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tcpEndpoints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadHeadHeader(t *testing.T) {
+	client, server := newTestConns(t, 68)
+
+	head := &types.Header{Number: big.NewInt(22000000), Time: 1745000000, Difficulty: big.NewInt(0)}
+	go func() {
+		req, ok := server.Read().(*GetBlockHeaders)
+		if !ok || req.Origin.Hash != head.Hash() {
+			server.Write(&Disconnect{})
+			return
+		}
+		server.Write(&Ping{})
+		if _, ok := server.Read().(*Pong); !ok {
+			return
+		}
+		server.Write(&BlockHeaders{RequestId: req.RequestId + 1})
+		server.Write(&BlockHeaders{
+			RequestId:           req.RequestId,
+			BlockHeadersRequest: eth.BlockHeadersRequest{head},
+		})
+	}()
+
+	info := common.ClientInfo{HeadHash: head.Hash()}
+	if _, err := readHeadHeader(client, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Blockheight != "22000000" || info.HeadTime != head.Time {
+		t.Fatalf("wrong head: height %s, time %d", info.Blockheight, info.HeadTime)
+	}
+}
+
+func TestReadHeadHeaderBlockRangeUpdate(t *testing.T) {
+	client, server := newTestConns(t, 69)
+
+	head := &types.Header{Number: big.NewInt(22000000), Time: 1745000000, Difficulty: big.NewInt(0)}
+	next := &types.Header{Number: big.NewInt(22000001), Time: 1745000012, Difficulty: big.NewInt(0)}
+	go func() {
+		req, ok := server.Read().(*GetBlockHeaders)
+		if !ok {
+			return
+		}
+		server.Write(&BlockRangeUpdate{EarliestBlock: 100, LatestBlock: 22000001, LatestBlockHash: next.Hash()})
+		server.Write(&BlockHeaders{
+			RequestId:           req.RequestId,
+			BlockHeadersRequest: eth.BlockHeadersRequest{head},
+		})
+	}()
+
+	info := common.ClientInfo{HeadHash: head.Hash()}
+	if _, err := readHeadHeader(client, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.HeadHash != head.Hash() || info.Blockheight != "22000000" || info.HeadTime != head.Time {
+		t.Fatalf("head mixed with the update: hash %v, height %s, time %d", info.HeadHash, info.Blockheight, info.HeadTime)
+	}
+	if info.EarliestBlock != 100 || info.LatestBlock != 22000001 {
+		t.Fatalf("block range not updated: %d-%d", info.EarliestBlock, info.LatestBlock)
+	}
+}
+
+func TestReadHeadHeaderDisconnect(t *testing.T) {
+	client, server := newTestConns(t, 68)
+	head := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(0)}
+	go func() {
+		server.Read()
+		server.Write(&Disconnect{Reason: p2p.DiscTooManyPeers})
+	}()
+
+	info := common.ClientInfo{HeadHash: head.Hash()}
+	if _, err := readHeadHeader(client, &info); !errors.Is(err, errConnLost) {
+		t.Fatalf("wrong error %v", err)
+	}
+}
+
+func TestReadHeadHeaderTimeout(t *testing.T) {
+	client, server := newTestConns(t, 68)
+	head := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(0)}
+	go server.Read()
+
+	client.SetDeadline(time.Now().Add(50 * time.Millisecond))
+	info := common.ClientInfo{HeadHash: head.Hash()}
+	if _, err := readHeadHeader(client, &info); !errors.Is(err, errConnLost) {
+		t.Fatalf("wrong error %v", err)
+	}
+}
+
+func TestDialIdentity(t *testing.T) {
+	serverKey, _ := crypto.GenerateKey()
+	ourKey, _ := crypto.GenerateKey()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	type result struct {
+		remote *ecdsa.PublicKey
+		hello  *Hello
+		err    error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		fd, err := ln.Accept()
+		if err != nil {
+			resc <- result{err: err}
+			return
+		}
+		conn := &Conn{Conn: rlpx.NewConn(fd, nil)}
+		defer conn.Close()
+		remote, err := conn.Handshake(serverKey)
+		if err != nil {
+			resc <- result{err: err}
+			return
+		}
+		hello, _ := conn.Read().(*Hello)
+		resc <- result{remote: remote, hello: hello}
+	}()
+
+	var r enr.Record
+	r.Set(enr.IPv4(net.IPv4(127, 0, 0, 1)))
+	r.Set(enr.TCP(ln.Addr().(*net.TCPAddr).Port))
+	if err := enode.SignV4(&r, serverKey); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, key, err := dial(n, false, ourKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if key != ourKey {
+		t.Fatal("dial did not use the given key")
+	}
+	if conn.connectTime <= 0 || conn.handshakeTime <= 0 {
+		t.Fatalf("dial timings not recorded: connect %v, handshake %v", conn.connectTime, conn.handshakeTime)
+	}
+	if err := writeHello(conn, key, "node-crawler", 30303); err != nil {
+		t.Fatal(err)
+	}
+
+	res := <-resc
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if !res.remote.Equal(&ourKey.PublicKey) {
+		t.Fatal("wrong remote identity")
+	}
+	if res.hello == nil || res.hello.Name != "node-crawler" || res.hello.ListenPort != 30303 {
+		t.Fatalf("wrong hello: %+v", res.hello)
+	}
+}
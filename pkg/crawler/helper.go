@@ -7,7 +7,6 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/enode"
-	"github.com/ethereum/go-ethereum/params"
 )
 
 func (c Crawler) makeDiscoveryConfig() (*enode.LocalNode, discover.Config) {
@@ -32,8 +31,10 @@ func (c Crawler) makeDiscoveryConfig() (*enode.LocalNode, discover.Config) {
 	return enode.NewLocalNode(c.NodeDB, cfg.PrivateKey), cfg
 }
 
+// listen opens the discovery socket. Listening on an unspecified address
+// without a family, like ":0", creates a dual-stack socket.
 func listen(ln *enode.LocalNode, addr string) *net.UDPConn {
-	socket, err := net.ListenPacket("udp4", addr)
+	socket, err := net.ListenPacket("udp", addr)
 	if err != nil {
 		panic(err)
 	}
@@ -49,7 +50,7 @@ func listen(ln *enode.LocalNode, addr string) *net.UDPConn {
 }
 
 func (c Crawler) parseBootnodes() ([]*enode.Node, error) {
-	bootnodes := params.MainnetBootnodes
+	bootnodes := c.Network.Bootnodes
 	if len(c.Bootnodes) != 0 {
 		bootnodes = c.Bootnodes
 	}
@@ -57,7 +58,7 @@ func (c Crawler) parseBootnodes() ([]*enode.Node, error) {
 	nodes := make([]*enode.Node, len(bootnodes))
 	var err error
 	for i, record := range bootnodes {
-		nodes[i], err = parseNode(record)
+		nodes[i], err = ParseNode(record)
 		if err != nil {
 			return nil, fmt.Errorf("invalid bootstrap node: %v", err)
 		}
@@ -0,0 +1,219 @@
+package crawler
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/node-crawler/pkg/common"
+)
+
+// defaultDialQueueSize is the capacity of the dial queue if none is configured.
+const defaultDialQueueSize = 512
+
+var (
+	dialQueueGauge  = metrics.NewRegisteredGauge("crawler/dial/queue", nil)
+	dialQueueNovel  = metrics.NewRegisteredGauge("crawler/dial/queue/novel", nil)
+	dialQueueFull   = metrics.NewRegisteredCounter("crawler/dial/queue/full", nil)
+	dialQueueWaited = metrics.NewRegisteredTimer("crawler/dial/queue/wait", nil)
+)
+
+// dialRequest is a node queued for dialing, with the duration of its ENR
+// request. The duration is zero if the request failed.
+type dialRequest struct {
+	n        *enode.Node
+	enrTime  time.Duration
+	priority dialPriority
+	// enrless is true for nodes which don't answer ENR requests.
+	enrless bool
+	// foreign is true for nodes whose record announces the fork ID of
+	// another network.
+	foreign bool
+
+	seq    uint64    // order of insertion, for FIFO among equals
+	queued time.Time // time of insertion, for the wait metric
+}
+
+// Failure classes, in the order they are dialed.
+const (
+	failureNone      = iota // never failed, or succeeded the last time
+	failureTransient        // timeouts, busy nodes, dropped connections
+	failurePermanent        // everything else, e.g. refused or incompatible
+)
+
+// dialPriority orders the dial queue. Nodes of other networks are dialed
+// last if they are deprioritized. Otherwise, nodes new to the crawler are
+// dialed first. Then nodes whose last dial failed for a transient reason are
+// preferred over those which failed permanently, nodes we got the client
+// info of longest ago (or never) over recently seen ones, and finally nodes
+// with a higher score.
+type dialPriority struct {
+	// foreign is only set if nodes of other networks are deprioritized.
+	foreign bool
+	novel   bool
+	failure int
+	// lastOK is the hour of the last successful dial since the epoch, zero
+	// if there was none. Hours are coarse enough for the score to matter.
+	lastOK int64
+	score  int
+}
+
+func newDialPriority(novel bool, node common.NodeJSON) dialPriority {
+	p := dialPriority{novel: novel, score: node.Score}
+	if !node.LastClientInfo.IsZero() {
+		p.lastOK = node.LastClientInfo.Unix() / 3600
+	}
+	switch {
+	case node.Failure == nil:
+		p.failure = failureNone
+	case node.Failure.TooManyPeers():
+		p.failure = failureTransient
+	default:
+		switch node.Failure.Kind {
+		case common.FailKindTimeout, common.FailKindClosed, common.FailKindNetwork:
+			p.failure = failureTransient
+		default:
+			p.failure = failurePermanent
+		}
+	}
+	return p
+}
+
+// before reports whether p should be dialed before q.
+func (p dialPriority) before(q dialPriority) bool {
+	if p.foreign != q.foreign {
+		return !p.foreign
+	}
+	if p.novel != q.novel {
+		return p.novel
+	}
+	if p.failure != q.failure {
+		return p.failure < q.failure
+	}
+	if p.lastOK != q.lastOK {
+		return p.lastOK < q.lastOK
+	}
+	return p.score > q.score
+}
+
+// dialHeap implements heap.Interface.
+type dialHeap []*dialRequest
+
+func (h dialHeap) Len() int { return len(h) }
+func (h dialHeap) Less(i, j int) bool {
+	if h[i].priority.before(h[j].priority) {
+		return true
+	}
+	if h[j].priority.before(h[i].priority) {
+		return false
+	}
+	return h[i].seq < h[j].seq
+}
+func (h dialHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *dialHeap) Push(x any)   { *h = append(*h, x.(*dialRequest)) }
+func (h *dialHeap) Pop() any {
+	old := *h
+	req := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return req
+}
+
+// dialQueue is a bounded priority queue of nodes to dial. It is safe for
+// concurrent use.
+type dialQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond // signalled when an item is added or removed, or on close
+	items  dialHeap
+	size   int
+	seq    uint64
+	closed bool
+}
+
+func newDialQueue(size int) *dialQueue {
+	if size <= 0 {
+		size = defaultDialQueueSize
+	}
+	q := &dialQueue{size: size}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push adds the request to the queue. It blocks while the queue is full, and
+// reports whether the request was added, which it isn't if the queue is
+// closed or the context is cancelled first.
+func (q *dialQueue) push(ctx context.Context, req dialRequest) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) >= q.size && !q.closed && ctx.Err() == nil {
+		dialQueueFull.Inc(1)
+		stop := context.AfterFunc(ctx, q.wake)
+		defer stop()
+		for len(q.items) >= q.size && !q.closed && ctx.Err() == nil {
+			q.cond.Wait()
+		}
+	}
+	if q.closed || ctx.Err() != nil {
+		return false
+	}
+
+	q.seq++
+	req.seq = q.seq
+	req.queued = time.Now()
+	heap.Push(&q.items, &req)
+	dialQueueGauge.Inc(1)
+	if req.priority.novel {
+		dialQueueNovel.Inc(1)
+	}
+	q.cond.Broadcast()
+	return true
+}
+
+// pop removes the request to dial next. It blocks while the queue is empty,
+// and returns false once the queue is closed and empty.
+func (q *dialQueue) pop() (dialRequest, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.items) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.items) == 0 {
+		return dialRequest{}, false
+	}
+
+	req := heap.Pop(&q.items).(*dialRequest)
+	dialQueueGauge.Dec(1)
+	if req.priority.novel {
+		dialQueueNovel.Dec(1)
+	}
+	dialQueueWaited.UpdateSince(req.queued)
+	q.cond.Broadcast()
+	return *req, true
+}
+
+// len returns the number of queued requests.
+func (q *dialQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// close wakes up all waiting callers. The requests left in the queue can
+// still be popped, but no more can be added.
+func (q *dialQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+func (q *dialQueue) wake() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.cond.Broadcast()
+}
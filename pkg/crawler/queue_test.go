@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/node-crawler/pkg/common"
+)
+
+func TestDialQueueOrder(t *testing.T) {
+	var (
+		now  = time.Now()
+		busy = &common.DialFailure{Kind: common.FailKindDisconnect, Reason: p2p.DiscTooManyPeers}
+		shut = &common.DialFailure{Kind: common.FailKindRefused}
+	)
+	// In the order they should be dialed.
+	nodes := []struct {
+		novel, foreign bool
+		node           common.NodeJSON
+	}{
+		{true, false, common.NodeJSON{Score: 1}},
+		{false, false, common.NodeJSON{Score: 5, LastClientInfo: now.Add(-48 * time.Hour)}},
+		{false, false, common.NodeJSON{Score: 20, LastClientInfo: now.Add(-time.Hour)}},
+		{false, false, common.NodeJSON{Score: 5, LastClientInfo: now.Add(-time.Hour)}},
+		{false, false, common.NodeJSON{Score: 5, LastClientInfo: now.Add(-time.Hour), Failure: busy}},
+		{false, false, common.NodeJSON{Score: 50, Failure: shut}},
+		{true, true, common.NodeJSON{Score: 100}},
+	}
+
+	q := newDialQueue(len(nodes))
+	for i := len(nodes) - 1; i >= 0; i-- {
+		req := dialRequest{enrTime: time.Duration(i), priority: newDialPriority(nodes[i].novel, nodes[i].node)}
+		req.priority.foreign = nodes[i].foreign
+		if !q.push(context.Background(), req) {
+			t.Fatal("push failed")
+		}
+	}
+	for i := range nodes {
+		req, ok := q.pop()
+		if !ok {
+			t.Fatal("pop failed")
+		}
+		if int(req.enrTime) != i {
+			t.Errorf("position %d: got node %d", i, req.enrTime)
+		}
+	}
+}
+
+func TestDialQueueFull(t *testing.T) {
+	q := newDialQueue(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if !q.push(ctx, dialRequest{}) {
+		t.Fatal("push failed")
+	}
+
+	// The push waits for room in the queue.
+	pushed := make(chan bool)
+	go func() { pushed <- q.push(ctx, dialRequest{enrTime: 1}) }()
+	select {
+	case <-pushed:
+		t.Fatal("push to full queue did not block")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, ok := q.pop(); !ok {
+		t.Fatal("pop failed")
+	}
+	if !<-pushed {
+		t.Fatal("push failed after pop")
+	}
+
+	// Cancelling the context aborts the push.
+	go func() { pushed <- q.push(ctx, dialRequest{enrTime: 2}) }()
+	cancel()
+	if <-pushed {
+		t.Fatal("push succeeded after cancel")
+	}
+
+	// The queued request can still be popped after closing.
+	q.close()
+	if req, ok := q.pop(); !ok || req.enrTime != 1 {
+		t.Fatalf("wrong request after close: %v %v", req.enrTime, ok)
+	}
+	if _, ok := q.pop(); ok {
+		t.Fatal("pop from closed and empty queue succeeded")
+	}
+}
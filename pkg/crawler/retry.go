@@ -0,0 +1,101 @@
+package crawler
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// backoff computes the delays between the dials of nodes which rejected us
+// because they have too many peers. Retries are disabled if base is zero.
+type backoff struct {
+	base, max time.Duration
+}
+
+func (b backoff) enabled() bool {
+	return b.base > 0
+}
+
+// delay returns the time to wait after the given number of rejected dials.
+// The delay doubles with every attempt up to the maximum, and a random
+// jitter of up to half the delay spreads out the retries of nodes which were
+// rejected at the same time.
+func (b backoff) delay(attempts int) time.Duration {
+	d := b.base
+	for i := 1; i < attempts && (b.max <= 0 || d < b.max); i++ {
+		d *= 2
+	}
+	if b.max > 0 && d > b.max {
+		d = b.max
+	}
+	return d - time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// scheduleDial queues the node for dialing at the given time, or right away
+// if the time has passed. Nodes which are already scheduled are skipped, and
+// nodes removed from the output set in the meantime are not dialed.
+func (c *crawler) scheduleDial(ctx context.Context, req dialRequest, at time.Time) {
+	id := req.n.ID()
+
+	c.Lock()
+	if _, ok := c.dials[id]; ok {
+		c.Unlock()
+		return
+	}
+	wait := time.Until(at)
+	if wait <= 0 {
+		c.dials[id] = nil
+		c.Unlock()
+		c.push(ctx, req)
+		return
+	}
+	// Retries are not scheduled once the crawl is shutting down.
+	select {
+	case <-c.closed:
+		c.Unlock()
+		return
+	default:
+	}
+	c.dials[id] = time.AfterFunc(wait, func() {
+		c.Lock()
+		_, live := c.output[id]
+		if req.enrless {
+			_, live = c.enrless[id]
+		}
+		if live {
+			c.dials[id] = nil
+		} else {
+			delete(c.dials, id)
+		}
+		c.Unlock()
+		if live {
+			c.push(ctx, req)
+		}
+	})
+	c.Unlock()
+}
+
+// push adds the scheduled request to the dial queue. If the queue is closed
+// or the context is cancelled first, the node is no longer scheduled, so it
+// can be scheduled again.
+func (c *crawler) push(ctx context.Context, req dialRequest) {
+	if c.queue.push(ctx, req) {
+		return
+	}
+	c.Lock()
+	delete(c.dials, req.n.ID())
+	c.Unlock()
+}
+
+// stopRetries cancels the scheduled retries. The nodes are retried when
+// they are checked again, at the time recorded in the output set.
+func (c *crawler) stopRetries() {
+	c.Lock()
+	defer c.Unlock()
+
+	for id, t := range c.dials {
+		if t != nil && t.Stop() {
+			delete(c.dials, id)
+		}
+	}
+}
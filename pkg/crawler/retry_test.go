@@ -0,0 +1,310 @@
+package crawler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/rlpx"
+	"github.com/ethereum/node-crawler/pkg/common"
+	"github.com/ethereum/node-crawler/pkg/networks"
+	"github.com/ethereum/node-crawler/pkg/simnet"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := backoff{base: time.Second, max: 10 * time.Second}
+	tests := []struct {
+		attempts int
+		max      time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			d := b.delay(tt.attempts)
+			if d < tt.max/2 || d > tt.max {
+				t.Fatalf("delay(%d) = %v, want between %v and %v", tt.attempts, d, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestRunRetryBusy(t *testing.T) {
+	nw := simnet.New(simnet.Config{Nodes: 50, Seed: 5})
+
+	var (
+		input = make(common.NodeSet)
+		// The node of the previous round may only be dialed after its
+		// backoff expires.
+		delayed  = nw.Nodes()[0]
+		nextDial = time.Now().Add(300 * time.Millisecond)
+	)
+	input[delayed.ID()] = common.NodeJSON{
+		N:            delayed,
+		Seq:          delayed.Seq(),
+		Score:        1,
+		TooManyPeers: true,
+		BusyAttempts: 1,
+		NextDial:     nextDial,
+	}
+
+	c := newTestCrawler(nw, input)
+	c.retry = backoff{base: 10 * time.Millisecond, max: 20 * time.Millisecond}
+
+	var (
+		mu    sync.Mutex
+		dials = make(map[enode.ID]int)
+		early bool
+	)
+	busy := newDisconnectError(common.FailPhaseHello, p2p.DiscTooManyPeers, errors.New("too many peers"))
+	c.clientInfo = func(n *enode.Node) (*common.ClientInfo, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if n.ID() == delayed.ID() && time.Now().Before(nextDial) {
+			early = true
+		}
+		// Every node rejects the first two dials.
+		if dials[n.ID()]++; dials[n.ID()] <= 2 {
+			return nil, busy
+		}
+		return &common.ClientInfo{ClientType: "Geth/v1.15.9-stable/linux-amd64/go1.24.2"}, nil
+	}
+
+	output := c.Run(context.Background(), time.Second)
+	if early {
+		t.Error("node dialed before its backoff expired")
+	}
+	if len(output) == 0 {
+		t.Fatal("no nodes found")
+	}
+	for id, n := range output {
+		if n.Info == nil {
+			t.Errorf("node %v not retried", id)
+			continue
+		}
+		want := 3
+		if id == delayed.ID() {
+			want = 4
+		}
+		if n.Info.DialAttempts != want || n.BusyAttempts != 0 || n.TooManyPeers {
+			t.Errorf("node %v: dial attempts %d, busy attempts %d, want %d", id, n.Info.DialAttempts, n.BusyAttempts, want)
+		}
+	}
+}
+
+func TestScheduleDialFailedPush(t *testing.T) {
+	nw := simnet.New(simnet.Config{Nodes: 2, Seed: 7})
+	n := nw.Nodes()[0]
+	c := newTestCrawler(nw, common.NodeSet{n.ID(): {N: n, Seq: n.Seq()}})
+
+	scheduled := func() bool {
+		c.Lock()
+		defer c.Unlock()
+		_, ok := c.dials[n.ID()]
+		return ok
+	}
+
+	// A retry whose context is cancelled before it is queued.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.scheduleDial(ctx, dialRequest{n: n}, time.Now().Add(10*time.Millisecond))
+	if !scheduled() {
+		t.Fatal("retry not scheduled")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if scheduled() {
+		t.Fatal("node still scheduled after its retry was cancelled")
+	}
+
+	// A dial after the queue is closed.
+	c.queue.close()
+	c.scheduleDial(context.Background(), dialRequest{n: n}, time.Time{})
+	if scheduled() {
+		t.Fatal("node still scheduled after the queue was closed")
+	}
+}
+
+// busyNode is a node which answers discovery requests, and rejects every
+// dial because it has too many peers.
+type busyNode struct {
+	n  *enode.Node
+	ln net.Listener
+
+	mu    sync.Mutex
+	dials []time.Time
+}
+
+func newBusyNode(t *testing.T) *busyNode {
+	key, _ := crypto.GenerateKey()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, _ := enode.OpenDB("")
+	local := enode.NewLocalNode(db, key)
+	local.SetStaticIP(net.IPv4(127, 0, 0, 1))
+	local.SetFallbackUDP(socket.LocalAddr().(*net.UDPAddr).Port)
+	local.Set(enr.TCP(ln.Addr().(*net.TCPAddr).Port))
+	// Like geth, discv5 gets the packets discv4 can't handle.
+	unhandled := make(chan discover.ReadPacket, 16)
+	v4, err := discover.ListenV4(socket, local, discover.Config{PrivateKey: key, Unhandled: unhandled})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v5, err := discover.ListenV5(&sharedUDPConn{socket, unhandled}, local, discover.Config{PrivateKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &busyNode{n: local.Node(), ln: ln}
+	go b.serve(key)
+	t.Cleanup(func() {
+		ln.Close()
+		// Closing discv4 closes the unhandled channel discv5 reads.
+		v4.Close()
+		v5.Close()
+		db.Close()
+	})
+	return b
+}
+
+// sharedUDPConn reads the packets left unhandled by discv4.
+type sharedUDPConn struct {
+	*net.UDPConn
+	unhandled chan discover.ReadPacket
+}
+
+func (s *sharedUDPConn) ReadFromUDPAddrPort(b []byte) (int, netip.AddrPort, error) {
+	packet, ok := <-s.unhandled
+	if !ok {
+		return 0, netip.AddrPort{}, net.ErrClosed
+	}
+	return copy(b, packet.Data), packet.Addr, nil
+}
+
+func (s *sharedUDPConn) Close() error {
+	return nil
+}
+
+func (b *busyNode) serve(key *ecdsa.PrivateKey) {
+	for {
+		fd, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.dials = append(b.dials, time.Now())
+		b.mu.Unlock()
+		go func() {
+			conn := &Conn{Conn: rlpx.NewConn(fd, nil)}
+			defer conn.Close()
+			if _, err := conn.Handshake(key); err != nil {
+				return
+			}
+			conn.Read()
+			conn.Write(&Disconnect{Reason: p2p.DiscTooManyPeers})
+			// Wait for the crawler to hang up.
+			fd.SetReadDeadline(time.Now().Add(5 * time.Second))
+			conn.Read()
+		}()
+	}
+}
+
+// dialsSince returns the times of the dials since the given time.
+func (b *busyNode) dialsSince(start time.Time) []time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var dials []time.Time
+	for _, d := range b.dials {
+		if !d.Before(start) {
+			dials = append(dials, d)
+		}
+	}
+	return dials
+}
+
+func TestCrawlRoundRetryBusy(t *testing.T) {
+	busy := newBusyNode(t)
+
+	mainnet, err := networks.Lookup("mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	network := *mainnet
+	network.DNSRoots = nil
+	nodeDB, _ := enode.OpenDB("")
+	defer nodeDB.Close()
+
+	// The retry is due after the first round ends.
+	c := Crawler{
+		Network:       &network,
+		ListenAddr:    "127.0.0.1:0",
+		Bootnodes:     []string{busy.n.String()},
+		Timeout:       100 * time.Millisecond,
+		Workers:       4,
+		NodeDB:        nodeDB,
+		RetryDelay:    4 * time.Second,
+		RetryMaxDelay: 4 * time.Second,
+	}
+	input := common.NodeSet{busy.n.ID(): {N: busy.n, Seq: busy.n.Seq(), Score: 10}}
+
+	start := time.Now()
+	output, err := c.CrawlRound(context.Background(), input, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, ok := output[busy.n.ID()]
+	if !ok {
+		t.Fatal("busy node dropped in the first round")
+	}
+	if !node.TooManyPeers || node.BusyAttempts != 1 || node.NextDial.IsZero() {
+		t.Fatalf("busy node not scheduled for a retry: too many peers %t, busy attempts %d, next dial %v", node.TooManyPeers, node.BusyAttempts, node.NextDial)
+	}
+	if len(busy.dialsSince(start)) == 0 {
+		t.Fatal("busy node not dialed in the first round")
+	}
+	if time.Now().After(node.NextDial) {
+		t.Skip("first round outlasted the backoff")
+	}
+
+	// The next round starts from the output of the first, and dials the node
+	// again once its backoff expires.
+	c.Timeout = 5 * time.Second
+	start = time.Now()
+	output, err = c.CrawlRound(context.Background(), output, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dials := busy.dialsSince(start)
+	if len(dials) == 0 {
+		t.Fatal("busy node not retried in the next round")
+	}
+	for _, d := range dials {
+		if d.Before(node.NextDial) {
+			t.Errorf("busy node dialed at %v, before its backoff expired at %v", d, node.NextDial)
+		}
+	}
+	if n := output[busy.n.ID()]; n.BusyAttempts < 2 {
+		t.Errorf("busy attempts not carried over: got %d, want at least 2", n.BusyAttempts)
+	}
+}
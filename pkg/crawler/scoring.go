@@ -0,0 +1,92 @@
+package crawler
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ethereum/node-crawler/pkg/common"
+)
+
+// ScoringPolicy decides the score of nodes after every check, and which nodes
+// are dropped from the output set. Implementations must be safe for
+// concurrent use.
+type ScoringPolicy interface {
+	// ENRChecked updates the score after an ENR request, which failed if ok
+	// is false.
+	ENRChecked(node *common.NodeJSON, ok bool)
+	// Dialed updates the score after getting the client info of the node,
+	// which failed if ok is false.
+	Dialed(node *common.NodeJSON, ok bool)
+	// Keep reports whether the node stays in the output set after an ENR
+	// request.
+	Keep(node *common.NodeJSON) bool
+}
+
+// NewScoringPolicy returns the policy of the given name, "default" or
+// "uptime".
+func NewScoringPolicy(name string) (ScoringPolicy, error) {
+	switch name {
+	case "", "default":
+		return DefaultScoring{}, nil
+	case "uptime":
+		return NewUptimeScoring(0.3, 0.1), nil
+	default:
+		return nil, fmt.Errorf("unknown scoring policy %q", name)
+	}
+}
+
+// DefaultScoring tracks how many liveness checks a node passed. An ENR
+// response adds one, a successful dial ten, and a failed ENR request halves
+// the score. Nodes are dropped once their score reaches zero.
+type DefaultScoring struct{}
+
+func (DefaultScoring) ENRChecked(node *common.NodeJSON, ok bool) {
+	if ok {
+		node.Score++
+	} else {
+		node.Score /= 2
+	}
+}
+
+func (DefaultScoring) Dialed(node *common.NodeJSON, ok bool) {
+	if ok {
+		node.Score += 10
+	}
+}
+
+func (DefaultScoring) Keep(node *common.NodeJSON) bool {
+	return node.Score > 0
+}
+
+// UptimeScoring scores nodes by their uptime ratio, the exponentially
+// weighted moving average of their ENR responses. The score is the ratio in
+// percent. Dials don't change the ratio, nodes which are too busy to talk to
+// us are still up. Nodes are dropped once the ratio falls below the minimum.
+type UptimeScoring struct {
+	alpha     float64 // weight of the latest check
+	minUptime float64
+}
+
+func NewUptimeScoring(alpha, minUptime float64) *UptimeScoring {
+	return &UptimeScoring{alpha: alpha, minUptime: minUptime}
+}
+
+func (s *UptimeScoring) ENRChecked(node *common.NodeJSON, ok bool) {
+	var up float64
+	if ok {
+		up = 1
+	}
+	// The first check of a node sets the ratio.
+	if len(node.ScoreHistory) == 0 && node.Uptime == 0 {
+		node.Uptime = up
+	} else {
+		node.Uptime = s.alpha*up + (1-s.alpha)*node.Uptime
+	}
+	node.Score = int(math.Round(node.Uptime * 100))
+}
+
+func (s *UptimeScoring) Dialed(*common.NodeJSON, bool) {}
+
+func (s *UptimeScoring) Keep(node *common.NodeJSON) bool {
+	return node.Uptime >= s.minUptime
+}
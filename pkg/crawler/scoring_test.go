@@ -0,0 +1,121 @@
+package crawler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/node-crawler/pkg/common"
+	"github.com/ethereum/node-crawler/pkg/simnet"
+)
+
+func TestDefaultScoring(t *testing.T) {
+	var (
+		s    DefaultScoring
+		node common.NodeJSON
+	)
+	s.ENRChecked(&node, true)
+	s.Dialed(&node, true)
+	s.ENRChecked(&node, true)
+	s.Dialed(&node, false)
+	if node.Score != 12 {
+		t.Fatalf("wrong score %d, want 12", node.Score)
+	}
+	for _, want := range []int{6, 3, 1, 0} {
+		s.ENRChecked(&node, false)
+		if node.Score != want {
+			t.Fatalf("wrong score %d, want %d", node.Score, want)
+		}
+		if s.Keep(&node) != (want > 0) {
+			t.Fatalf("wrong keep for score %d", node.Score)
+		}
+	}
+}
+
+func TestUptimeScoring(t *testing.T) {
+	var (
+		s    = NewUptimeScoring(0.5, 0.2)
+		node common.NodeJSON
+	)
+	s.ENRChecked(&node, true)
+	node.RecordScore(common.CheckENR, true)
+	if node.Score != 100 {
+		t.Fatalf("wrong score %d after first check", node.Score)
+	}
+	s.Dialed(&node, false)
+	for _, want := range []int{50, 25, 13} {
+		s.ENRChecked(&node, false)
+		node.RecordScore(common.CheckENR, false)
+		if node.Score != want {
+			t.Fatalf("wrong score %d, want %d", node.Score, want)
+		}
+	}
+	if s.Keep(&node) {
+		t.Fatalf("node with uptime %v kept", node.Uptime)
+	}
+
+	// A node which was down at its first check starts at zero.
+	var down common.NodeJSON
+	s.ENRChecked(&down, false)
+	if down.Score != 0 || s.Keep(&down) {
+		t.Fatalf("wrong score %d", down.Score)
+	}
+}
+
+func TestScoreHistory(t *testing.T) {
+	var node common.NodeJSON
+	for i := 0; i < 20; i++ {
+		node.Score = i
+		node.RecordScore(common.CheckENR, true)
+	}
+	if len(node.ScoreHistory) != 16 {
+		t.Fatalf("wrong history length %d", len(node.ScoreHistory))
+	}
+	if first := node.ScoreHistory[0].Score; first != 4 {
+		t.Fatalf("wrong oldest score %d", first)
+	}
+
+	// Copies of the node don't share the appended history.
+	cpy := node
+	cpy.RecordScore(common.CheckDial, false)
+	node.RecordScore(common.CheckDial, true)
+	if !node.ScoreHistory[15].OK || cpy.ScoreHistory[15].OK {
+		t.Fatal("history shared between copies")
+	}
+}
+
+func TestRunScoringPolicy(t *testing.T) {
+	nw := simnet.New(simnet.Config{Nodes: 100, Seed: 6, IteratorLimit: 1})
+
+	var (
+		input   = make(common.NodeSet)
+		offline = nw.Nodes()[0]
+		online  = nw.Nodes()[1]
+	)
+	for _, n := range []*enode.Node{offline, online} {
+		input[n.ID()] = common.NodeJSON{
+			N:            n,
+			Seq:          n.Seq(),
+			Score:        90,
+			Uptime:       0.9,
+			ScoreHistory: []common.ScoreEvent{{Check: common.CheckENR, OK: true, Score: 90}},
+		}
+	}
+	nw.SetOnline(offline.ID(), false)
+
+	c := newTestCrawler(nw, input)
+	c.scoring = NewUptimeScoring(0.5, 0.5)
+	output := c.Run(context.Background(), time.Minute)
+
+	if _, ok := output[offline.ID()]; ok {
+		t.Error("offline node not removed")
+	}
+	node := output[online.ID()]
+	if node.Score != 95 || node.Uptime != 0.95 {
+		t.Errorf("wrong score of online node: %d, uptime %v", node.Score, node.Uptime)
+	}
+	if h := node.ScoreHistory; len(h) != 3 || h[1].Check != common.CheckENR || h[2].Check != common.CheckDial {
+		t.Errorf("wrong history %v", h)
+	}
+}
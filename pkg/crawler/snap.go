@@ -0,0 +1,88 @@
+package crawler
+
+import (
+	"math/rand"
+	"time"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/node-crawler/pkg/common"
+)
+
+const (
+	// snapProbeTimeout is the time a node has to answer the snap probe.
+	snapProbeTimeout = 5 * time.Second
+	// snapProbeBytes is the soft limit of the response size we ask for.
+	snapProbeBytes = 4096
+)
+
+// probeSnap requests a small amount of snap data from the node, and records
+// whether and how it answered. The state of the node's head is requested if
+// its header is known, the empty contract code otherwise.
+func probeSnap(conn *Conn, head *ethTypes.Header) *common.SnapProbe {
+	var (
+		probe common.SnapProbe
+		reqID = rand.Uint64()
+		req   Message
+	)
+	if head != nil {
+		req = &GetAccountRange{
+			ID:    reqID,
+			Root:  head.Root,
+			Limit: ethCommon.MaxHash,
+			Bytes: snapProbeBytes,
+		}
+	} else {
+		req = &GetByteCodes{
+			ID:     reqID,
+			Hashes: []ethCommon.Hash{ethTypes.EmptyCodeHash},
+			Bytes:  snapProbeBytes,
+		}
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(snapProbeTimeout)); err != nil {
+		log.Debug("Cannot set snap probe deadline", "err", err)
+		return &probe
+	}
+	start := time.Now()
+	if err := conn.Write(req); err != nil {
+		log.Debug("Cannot send snap probe", "err", err)
+		return &probe
+	}
+
+	for {
+		var resp Message
+		switch msg := conn.Read().(type) {
+		case *AccountRange:
+			resp = msg
+		case *ByteCodes:
+			resp = msg
+		case *Ping:
+			if err := conn.Write(Pong{}); err != nil {
+				return &probe
+			}
+			continue
+		case *Disconnect:
+			log.Debug("Disconnected during snap probe", "reason", msg.Reason)
+			return &probe
+		case *Error:
+			log.Debug("Snap probe failed", "err", msg)
+			return &probe
+		default:
+			// Ignore announcements and requests sent by the node.
+			continue
+		}
+		if resp.ReqID() != reqID {
+			continue
+		}
+
+		probe.Answered = true
+		probe.Latency = time.Since(start)
+		if payload, err := rlp.EncodeToBytes(resp); err == nil {
+			probe.ResponseSize = uint64(len(payload))
+		}
+		return &probe
+	}
+}
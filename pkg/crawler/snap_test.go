@@ -0,0 +1,54 @@
+package crawler
+
+import (
+	"math/big"
+	"testing"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/snap"
+)
+
+func TestProbeSnap(t *testing.T) {
+	client, server := newTestConns(t, 68)
+	client.negotiatedSnapProtoVersion = 1
+	server.negotiatedSnapProtoVersion = 1
+
+	head := &types.Header{Number: big.NewInt(1), Root: ethCommon.HexToHash("0x01"), Difficulty: big.NewInt(0)}
+	go func() {
+		req, ok := server.Read().(*GetAccountRange)
+		if !ok || req.Root != head.Root {
+			server.Write(&Disconnect{})
+			return
+		}
+		server.Write(&AccountRange{ID: req.ID + 1})
+		server.Write(&AccountRange{
+			ID:       req.ID,
+			Accounts: []*snap.AccountData{{Hash: ethCommon.HexToHash("0x02"), Body: []byte{0xc0}}},
+		})
+	}()
+
+	probe := probeSnap(client, head)
+	if !probe.Answered {
+		t.Fatal("probe not answered")
+	}
+	if probe.ResponseSize == 0 || probe.Latency == 0 {
+		t.Fatalf("wrong probe result %+v", probe)
+	}
+}
+
+func TestProbeSnapDisconnect(t *testing.T) {
+	client, server := newTestConns(t, 69)
+	client.negotiatedSnapProtoVersion = 1
+	server.negotiatedSnapProtoVersion = 1
+
+	go func() {
+		if _, ok := server.Read().(*GetByteCodes); ok {
+			server.Write(&Disconnect{})
+		}
+	}()
+
+	if probe := probeSnap(client, nil); probe.Answered {
+		t.Fatal("disconnected probe answered")
+	}
+}
@@ -0,0 +1,235 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// statusTimeout is the time allowed for a provider to return the head.
+const statusTimeout = 5 * time.Second
+
+// StatusProvider provides the head of the chain which the crawler announces
+// in its status messages. Implementations must be safe for concurrent use.
+type StatusProvider interface {
+	Head(ctx context.Context) (*ethTypes.Header, error)
+}
+
+// StaticStatus always provides the same head, usually the genesis block.
+type StaticStatus struct {
+	head *ethTypes.Header
+}
+
+func NewStaticStatus(head *ethTypes.Header) *StaticStatus {
+	return &StaticStatus{head: head}
+}
+
+func (s *StaticStatus) Head(context.Context) (*ethTypes.Header, error) {
+	return s.head, nil
+}
+
+// RPCStatus fetches the latest header from a node over JSON-RPC on every call.
+type RPCStatus struct {
+	client *ethclient.Client
+}
+
+func NewRPCStatus(url string) (*RPCStatus, error) {
+	client, err := ethclient.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("cannot dial node: %w", err)
+	}
+	return &RPCStatus{client: client}, nil
+}
+
+func (s *RPCStatus) Head(ctx context.Context) (*ethTypes.Header, error) {
+	header, err := s.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get latest header: %w", err)
+	}
+	return header, nil
+}
+
+func (s *RPCStatus) Close() {
+	s.client.Close()
+}
+
+// CachedStatus caches the head of another provider, and refreshes it once it
+// is older than the refresh interval. While the head is refreshed, or when
+// refreshing fails, the last known head is provided.
+type CachedStatus struct {
+	provider StatusProvider
+	interval time.Duration
+
+	// refreshMu is held while fetching a new head, so only one caller
+	// waits for the provider.
+	refreshMu sync.Mutex
+
+	mu      sync.RWMutex
+	head    *ethTypes.Header
+	updated time.Time
+}
+
+func NewCachedStatus(provider StatusProvider, interval time.Duration) *CachedStatus {
+	return &CachedStatus{
+		provider: provider,
+		interval: interval,
+	}
+}
+
+func (s *CachedStatus) Head(ctx context.Context) (*ethTypes.Header, error) {
+	head, fresh := s.cached()
+	if fresh {
+		return head, nil
+	}
+
+	if head == nil {
+		// Nothing to fall back to, wait for the refresh.
+		s.refreshMu.Lock()
+	} else if !s.refreshMu.TryLock() {
+		return head, nil
+	}
+	defer s.refreshMu.Unlock()
+
+	// Another caller might have refreshed the head in the meantime.
+	head, fresh = s.cached()
+	if fresh {
+		return head, nil
+	}
+
+	newHead, err := s.provider.Head(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		if head == nil {
+			return nil, err
+		}
+		// Keep the stale head, and retry after the interval.
+		log.Warn("Cannot refresh head, using stale head", "number", head.Number, "err", err)
+		s.updated = time.Now()
+		return head, nil
+	}
+	s.head, s.updated = newHead, time.Now()
+	return newHead, nil
+}
+
+func (s *CachedStatus) cached() (head *ethTypes.Header, fresh bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.head, s.head != nil && time.Since(s.updated) < s.interval
+}
+
+// chainStatus creates the status messages of a crawler from the head of its
+// provider. It is safe for concurrent use.
+type chainStatus struct {
+	provider  StatusProvider
+	config    *params.ChainConfig
+	genesis   *ethTypes.Block
+	networkID uint64
+
+	mu sync.Mutex
+	td *big.Int
+}
+
+func newChainStatus(genesis *core.Genesis, networkID uint64, provider StatusProvider) *chainStatus {
+	block := genesis.ToBlock()
+	if provider == nil {
+		provider = NewStaticStatus(block.Header())
+	}
+	return &chainStatus{
+		provider:  provider,
+		config:    genesis.Config,
+		genesis:   block,
+		networkID: networkID,
+		td:        big.NewInt(0),
+	}
+}
+
+// message returns our status message for the given eth protocol version. If
+// the provider has no head, the genesis block is announced, like the crawler
+// does without a node.
+func (s *chainStatus) message(ctx context.Context, version uint32) (Message, error) {
+	head, err := s.provider.Head(ctx)
+	if err != nil {
+		log.Warn("Cannot get head, announcing genesis", "err", err)
+		head = s.genesis.Header()
+	}
+	number := head.Number.Uint64()
+	forkID := forkid.NewID(s.config, s.genesis, number, head.Time)
+
+	if version >= 69 {
+		return &Status69{
+			ProtocolVersion: version,
+			NetworkID:       s.networkID,
+			Genesis:         s.genesis.Hash(),
+			ForkID:          forkID,
+			EarliestBlock:   0,
+			LatestBlock:     number,
+			LatestBlockHash: head.Hash(),
+		}, nil
+	}
+
+	s.mu.Lock()
+	td := new(big.Int).Set(s.td)
+	s.mu.Unlock()
+
+	return &Status{
+		ProtocolVersion: version,
+		NetworkID:       s.networkID,
+		TD:              td,
+		Head:            head.Hash(),
+		Genesis:         s.genesis.Hash(),
+		ForkID:          forkID,
+	}, nil
+}
+
+// observeTD raises the total difficulty we announce if a node announced a
+// higher one.
+func (s *chainStatus) observeTD(td *big.Int) {
+	if td == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if td.Cmp(s.td) > 0 {
+		s.td = new(big.Int).Set(td)
+	}
+}
+
+// Config, Genesis and CurrentHeader implement forkid.Blockchain.
+
+func (s *chainStatus) Config() *params.ChainConfig { return s.config }
+
+func (s *chainStatus) Genesis() *ethTypes.Block { return s.genesis }
+
+// CurrentHeader returns the head of the provider. If the provider has no head
+// past genesis, all block based forks are assumed to have passed, and the
+// current time is used for the timestamp based forks.
+func (s *chainStatus) CurrentHeader() *ethTypes.Header {
+	ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+	defer cancel()
+
+	head, err := s.provider.Head(ctx)
+	if err == nil && head.Number.Sign() > 0 {
+		return head
+	}
+	return &ethTypes.Header{
+		// MaxUint64 is the sentry of the forkid filter, stay below it.
+		Number: new(big.Int).SetUint64(math.MaxUint64 - 1),
+		Time:   uint64(time.Now().Unix()),
+	}
+}
@@ -0,0 +1,205 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fakeEth is the eth namespace of a fake JSON-RPC server.
+type fakeEth struct {
+	calls  atomic.Int64
+	number atomic.Uint64
+}
+
+func (f *fakeEth) GetBlockByNumber(number rpc.BlockNumber, full bool) (*types.Header, error) {
+	f.calls.Add(1)
+	if number != rpc.LatestBlockNumber {
+		return nil, errors.New("only the latest block is available")
+	}
+	return &types.Header{
+		Number:     new(big.Int).SetUint64(f.number.Load()),
+		Time:       1745000000,
+		Difficulty: big.NewInt(0),
+	}, nil
+}
+
+func newFakeRPC(t *testing.T) (*fakeEth, string) {
+	eth := new(fakeEth)
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("eth", eth); err != nil {
+		t.Fatal(err)
+	}
+	httpSrv := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		httpSrv.Close()
+		srv.Stop()
+	})
+	return eth, httpSrv.URL
+}
+
+func TestRPCStatus(t *testing.T) {
+	eth, url := newFakeRPC(t)
+	eth.number.Store(22000000)
+
+	status, err := NewRPCStatus(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer status.Close()
+
+	for i := 0; i < 2; i++ {
+		head, err := status.Head(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head.Number.Uint64() != 22000000 {
+			t.Fatalf("wrong head number %v", head.Number)
+		}
+	}
+	if calls := eth.calls.Load(); calls != 2 {
+		t.Fatalf("wrong number of RPC calls: %d", calls)
+	}
+}
+
+func TestCachedStatus(t *testing.T) {
+	eth, url := newFakeRPC(t)
+	eth.number.Store(1)
+
+	rpcStatus, err := NewRPCStatus(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rpcStatus.Close()
+	status := NewCachedStatus(rpcStatus, 100*time.Millisecond)
+
+	// Concurrent callers share the head fetched by the first one.
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			head, err := status.Head(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if head.Number.Uint64() != 1 {
+				t.Errorf("wrong head number %v", head.Number)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls := eth.calls.Load(); calls != 1 {
+		t.Fatalf("wrong number of RPC calls: %d", calls)
+	}
+
+	// The head is refreshed after the interval.
+	eth.number.Store(2)
+	time.Sleep(150 * time.Millisecond)
+	head, err := status.Head(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Number.Uint64() != 2 {
+		t.Fatalf("head not refreshed: %v", head.Number)
+	}
+}
+
+type failingStatus struct{}
+
+func (failingStatus) Head(context.Context) (*types.Header, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestCachedStatusStale(t *testing.T) {
+	status := NewCachedStatus(failingStatus{}, time.Hour)
+	if _, err := status.Head(context.Background()); err == nil {
+		t.Fatal("expected error without a head")
+	}
+
+	// A failed refresh keeps the last known head.
+	stale := &types.Header{Number: big.NewInt(5)}
+	status.head, status.updated = stale, time.Now().Add(-2*time.Hour)
+	head, err := status.Head(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != stale {
+		t.Fatalf("wrong head %v", head.Number)
+	}
+}
+
+func TestChainStatusMessage(t *testing.T) {
+	genesis := core.DefaultGenesisBlock()
+	head := &types.Header{Number: big.NewInt(22000000), Time: 1745000000}
+	cs := newChainStatus(genesis, 1, NewStaticStatus(head))
+	cs.observeTD(big.NewInt(100))
+	cs.observeTD(big.NewInt(50))
+
+	wantForkID := forkid.NewID(params.MainnetChainConfig, cs.genesis, 22000000, 1745000000)
+
+	msg, err := cs.message(context.Background(), 68)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, ok := msg.(*Status)
+	if !ok {
+		t.Fatalf("wrong message %T", msg)
+	}
+	if status.Head != head.Hash() || status.ForkID != wantForkID || status.TD.Int64() != 100 {
+		t.Fatalf("wrong status %+v", status)
+	}
+
+	msg, err = cs.message(context.Background(), 69)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status69, ok := msg.(*Status69)
+	if !ok {
+		t.Fatalf("wrong message %T", msg)
+	}
+	if status69.LatestBlock != 22000000 || status69.LatestBlockHash != head.Hash() || status69.ForkID != wantForkID {
+		t.Fatalf("wrong status %+v", status69)
+	}
+}
+
+func TestChainStatusProviderDown(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	rpcStatus, err := NewRPCStatus(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rpcStatus.Close()
+
+	// Without a cached head, the genesis block is announced.
+	genesis := core.DefaultGenesisBlock()
+	cs := newChainStatus(genesis, 1, NewCachedStatus(rpcStatus, time.Hour))
+	msg, err := cs.message(context.Background(), 68)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, ok := msg.(*Status)
+	if !ok {
+		t.Fatalf("wrong message %T", msg)
+	}
+	wantForkID := forkid.NewID(params.MainnetChainConfig, cs.genesis, 0, cs.genesis.Time())
+	if status.Head != params.MainnetGenesisHash || status.ForkID != wantForkID {
+		t.Fatalf("wrong status %+v", status)
+	}
+}
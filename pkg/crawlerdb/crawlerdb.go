@@ -2,6 +2,9 @@ package crawlerdb
 
 import (
 	"database/sql"
+
+	"github.com/ethereum/node-crawler/pkg/estimate"
+	"github.com/ethereum/node-crawler/pkg/networks"
 )
 
 type CrawledNode struct {
@@ -13,31 +16,112 @@ type CrawledNode struct {
 	NetworkID       uint64
 	Country         string
 	ForkID          string
+	IPFamily        string
+	EarliestBlock   sql.NullInt64
+	LatestBlock     sql.NullInt64
+	Blockheight     sql.NullInt64
+	HeadTime        sql.NullInt64
+	ForkCompat      string
+	SnapAnswered    sql.NullInt64
+	// SnapLatency is in milliseconds.
+	SnapLatency      sql.NullInt64
+	SnapResponseSize sql.NullInt64
+	FailurePhase     string
+	FailureKind      string
+	DiscReason       sql.NullInt64
+	ASN              sql.NullInt64
+	ASNOrg           string
+	// The latencies of the crawl phases are in milliseconds.
+	ENRLatency       sql.NullInt64
+	ConnectLatency   sql.NullInt64
+	HandshakeLatency sql.NullInt64
+	HelloLatency     sql.NullInt64
+	StatusLatency    sql.NullInt64
+	// DialAttempts is the number of dials it took to get the client info.
+	DialAttempts sql.NullInt64
+	// DiscProtocols are the discovery protocols which found the node.
+	DiscProtocols string
+	// Whether the node answered the last ENR request of each protocol, and
+	// whether it answers discv4 ENR requests at all.
+	DiscV4Alive sql.NullInt64
+	DiscV5Alive sql.NullInt64
+	EIP868      sql.NullInt64
+	// ENRLess marks nodes which don't answer ENR requests.
+	ENRLess bool
+	// ENRClient is the client identity of the node record (EIP-7636), as
+	// "name/version" or "name/version/build".
+	ENRClient string
+	// Chain and ForkStage label the node with a known chain, see
+	// networks.Registry.
+	Chain     string
+	ForkStage string
 }
 
+// crawledNodeColumns are the columns of a CrawledNode.
+const crawledNodeColumns = `
+	ID,
+	Now,
+	ClientType,
+	SoftwareVersion,
+	Capabilities,
+	NetworkID,
+	Country,
+	ForkID,
+	COALESCE(IPFamily, ''),
+	EarliestBlock,
+	LatestBlock,
+	CAST(NULLIF(Blockheight, '') AS INTEGER),
+	HeadTime,
+	COALESCE(ForkCompat, ''),
+	SnapAnswered,
+	SnapLatency,
+	SnapResponseSize,
+	COALESCE(FailurePhase, ''),
+	COALESCE(FailureKind, ''),
+	DiscReason,
+	ASN,
+	COALESCE(ASNOrg, ''),
+	ENRLatency,
+	ConnectLatency,
+	HandshakeLatency,
+	HelloLatency,
+	StatusLatency,
+	DialAttempts,
+	COALESCE(DiscProtocols, ''),
+	DiscV4Alive,
+	DiscV5Alive,
+	EIP868,
+	COALESCE(ENRLess, 0),
+	COALESCE(ENRClient, ''),
+	COALESCE(Chain, ''),
+	COALESCE(ForkStage, '')
+`
+
 func ReadAndDeleteUnseenNodes(db *sql.Tx) ([]CrawledNode, error) {
-	queryStmt := `
-		DELETE FROM nodes
-		RETURNING
-			ID,
-			Now,
-			ClientType,
-			SoftwareVersion,
-			Capabilities,
-			NetworkID,
-			Country,
-			ForkID
-	`
-	rows, err := db.Query(queryStmt)
+	rows, err := db.Query(`DELETE FROM nodes RETURNING` + crawledNodeColumns)
+	if err != nil {
+		return nil, err
+	}
+	return scanCrawledNodes(rows)
+}
 
+// ReadNode returns the rows of the node which were not transferred to the
+// API yet, oldest first.
+func ReadNode(db *sql.DB, id string) ([]CrawledNode, error) {
+	rows, err := db.Query(`SELECT`+crawledNodeColumns+`FROM nodes WHERE ID = ? ORDER BY Now`, id)
 	if err != nil {
 		return nil, err
 	}
+	return scanCrawledNodes(rows)
+}
+
+func scanCrawledNodes(rows *sql.Rows) ([]CrawledNode, error) {
+	defer rows.Close()
 
 	var nodes []CrawledNode
 	for rows.Next() {
 		var node CrawledNode
-		err = rows.Scan(
+		err := rows.Scan(
 			&node.ID,
 			&node.Now,
 			&node.ClientType,
@@ -46,11 +130,193 @@ func ReadAndDeleteUnseenNodes(db *sql.Tx) ([]CrawledNode, error) {
 			&node.NetworkID,
 			&node.Country,
 			&node.ForkID,
+			&node.IPFamily,
+			&node.EarliestBlock,
+			&node.LatestBlock,
+			&node.Blockheight,
+			&node.HeadTime,
+			&node.ForkCompat,
+			&node.SnapAnswered,
+			&node.SnapLatency,
+			&node.SnapResponseSize,
+			&node.FailurePhase,
+			&node.FailureKind,
+			&node.DiscReason,
+			&node.ASN,
+			&node.ASNOrg,
+			&node.ENRLatency,
+			&node.ConnectLatency,
+			&node.HandshakeLatency,
+			&node.HelloLatency,
+			&node.StatusLatency,
+			&node.DialAttempts,
+			&node.DiscProtocols,
+			&node.DiscV4Alive,
+			&node.DiscV5Alive,
+			&node.EIP868,
+			&node.ENRLess,
+			&node.ENRClient,
+			&node.Chain,
+			&node.ForkStage,
 		)
 		if err != nil {
 			return nil, err
 		}
 		nodes = append(nodes, node)
 	}
-	return nodes, nil
+	return nodes, rows.Err()
+}
+
+// RoundEstimate is a size estimate of a crawl round.
+type RoundEstimate struct {
+	// Round is the start time of the round, in RFC 3339 format.
+	Round string
+	estimate.SizeEstimate
+}
+
+func ReadAndDeleteEstimates(db *sql.Tx) ([]RoundEstimate, error) {
+	rows, err := db.Query(`
+		DELETE FROM estimates
+		RETURNING
+			Round,
+			Method,
+			Client,
+			NetworkID,
+			First,
+			Second,
+			Both,
+			Size,
+			Lower,
+			Upper
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var estimates []RoundEstimate
+	for rows.Next() {
+		var e RoundEstimate
+		err = rows.Scan(
+			&e.Round,
+			&e.Method,
+			&e.Client,
+			&e.NetworkID,
+			&e.First,
+			&e.Second,
+			&e.Both,
+			&e.Size,
+			&e.Lower,
+			&e.Upper,
+		)
+		if err != nil {
+			return nil, err
+		}
+		estimates = append(estimates, e)
+	}
+	return estimates, rows.Err()
+}
+
+// ChainCount is the number of nodes of a chain at a fork stage in a crawl
+// round.
+type ChainCount struct {
+	// Round is the start time of the round, in RFC 3339 format.
+	Round string
+	networks.Label
+	Nodes int
+}
+
+func ReadAndDeleteChainCounts(db *sql.Tx) ([]ChainCount, error) {
+	rows, err := db.Query(`
+		DELETE FROM chains
+		RETURNING
+			Round,
+			Chain,
+			ForkStage,
+			Nodes
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []ChainCount
+	for rows.Next() {
+		var c ChainCount
+		if err := rows.Scan(&c.Round, &c.Chain, &c.Stage, &c.Nodes); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// ENRAttr is a key/value pair of the record of a node.
+type ENRAttr struct {
+	ID  string
+	Now string
+	Seq uint64
+	Key string
+	// Value is decoded if Decoded is set, hex encoded otherwise. Raw is the
+	// hex encoded RLP value.
+	Value   string
+	Raw     string
+	Decoded bool
+}
+
+// enrAttrColumns are the columns of an ENRAttr.
+const enrAttrColumns = `
+	ID,
+	Now,
+	Seq,
+	Key,
+	COALESCE(Value, ''),
+	COALESCE(Raw, ''),
+	COALESCE(Decoded, 0)
+`
+
+func ReadAndDeleteENRAttrs(db *sql.Tx) ([]ENRAttr, error) {
+	rows, err := db.Query(`DELETE FROM ENRAttrs RETURNING` + enrAttrColumns)
+	if err != nil {
+		return nil, err
+	}
+	return scanENRAttrs(rows)
+}
+
+// ReadENRAttrs returns the key/value pairs of the latest record of the node
+// which was not transferred to the API yet.
+func ReadENRAttrs(db *sql.DB, id string) ([]ENRAttr, error) {
+	rows, err := db.Query(`
+		SELECT`+enrAttrColumns+`
+		FROM ENRAttrs
+		WHERE ID = ? AND Now = (SELECT MAX(Now) FROM ENRAttrs WHERE ID = ?)
+		ORDER BY Key
+	`, id, id)
+	if err != nil {
+		return nil, err
+	}
+	return scanENRAttrs(rows)
+}
+
+func scanENRAttrs(rows *sql.Rows) ([]ENRAttr, error) {
+	defer rows.Close()
+
+	var attrs []ENRAttr
+	for rows.Next() {
+		var a ENRAttr
+		err := rows.Scan(
+			&a.ID,
+			&a.Now,
+			&a.Seq,
+			&a.Key,
+			&a.Value,
+			&a.Raw,
+			&a.Decoded,
+		)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, a)
+	}
+	return attrs, rows.Err()
 }
@@ -3,7 +3,10 @@ package crawlerdb
 import (
 	"bytes"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/netip"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -11,6 +14,9 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/node-crawler/pkg/common"
+	"github.com/ethereum/node-crawler/pkg/enrattr"
+	"github.com/ethereum/node-crawler/pkg/estimate"
+	"github.com/ethereum/node-crawler/pkg/networks"
 
 	beacon "github.com/protolambda/zrnt/eth2/beacon/common"
 	"github.com/protolambda/ztyp/codec"
@@ -23,7 +29,10 @@ type ETH2 []byte
 
 func (v ETH2) ENRKey() string { return "eth2" }
 
-func UpdateNodes(db *sql.DB, geoipDB *geoip2.Reader, nodes []common.NodeJSON) error {
+// UpdateNodes writes the nodes of a round to the database. The GeoIP city and
+// ASN databases are optional, and so are the chains the nodes are labeled
+// with.
+func UpdateNodes(db *sql.DB, geoipDB, asnDB *geoip2.Reader, chains *networks.Registry, nodes []common.NodeJSON) error {
 	log.Info("Writing nodes to db", "nodes", len(nodes))
 
 	now := time.Now()
@@ -31,6 +40,8 @@ func UpdateNodes(db *sql.DB, geoipDB *geoip2.Reader, nodes []common.NodeJSON) er
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
 	stmt, err := tx.Prepare(
 		`INSERT INTO nodes(
 			ID,
@@ -52,19 +63,67 @@ func UpdateNodes(db *sql.DB, geoipDB *geoip2.Reader, nodes []common.NodeJSON) er
 			LastSeen,
 			Seq,
 			Score,
-			ConnType
-		) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+			ConnType,
+			IPFamily,
+			EarliestBlock,
+			LatestBlock,
+			HeadTime,
+			ForkCompat,
+			SnapAnswered,
+			SnapLatency,
+			SnapResponseSize,
+			FailurePhase,
+			FailureKind,
+			DiscReason,
+			ASN,
+			ASNOrg,
+			ENRLatency,
+			ConnectLatency,
+			HandshakeLatency,
+			HelloLatency,
+			StatusLatency,
+			DialAttempts,
+			DiscProtocols,
+			DiscV4Alive,
+			DiscV5Alive,
+			EIP868,
+			ENRLess,
+			ENRClient,
+			Chain,
+			ForkStage
+		) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
 	)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
+	attrStmt, err := tx.Prepare(
+		`INSERT INTO ENRAttrs(
+			ID,
+			Now,
+			Seq,
+			Key,
+			Value,
+			Raw,
+			Decoded
+		) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+	)
+	if err != nil {
+		return err
+	}
+	defer attrStmt.Close()
+
 	for _, n := range nodes {
 		info := &common.ClientInfo{}
 		if n.Info != nil {
 			info = n.Info
 		}
+		// Failed dials have timings too, but no info.
+		var timings common.Timings
+		if n.Timings != nil {
+			timings = *n.Timings
+		}
 
 		if info.ClientType == "" && n.TooManyPeers {
 			info.ClientType = "tmp"
@@ -89,6 +148,60 @@ func UpdateNodes(db *sql.DB, geoipDB *geoip2.Reader, nodes []common.NodeJSON) er
 				fid = fmt.Sprintf("Hash: %v, Next %v", dat.ForkDigest, dat.NextForkEpoch)
 			}
 		}
+		// The block range is only known for eth/69 nodes.
+		var earliestBlock, latestBlock sql.NullInt64
+		if info.LatestBlock != 0 {
+			earliestBlock = sql.NullInt64{Int64: int64(info.EarliestBlock), Valid: true}
+			latestBlock = sql.NullInt64{Int64: int64(info.LatestBlock), Valid: true}
+		}
+
+		var headTime sql.NullInt64
+		if info.HeadTime != 0 {
+			headTime = sql.NullInt64{Int64: int64(info.HeadTime), Valid: true}
+		}
+
+		var eip868 sql.NullInt64
+		if supported, ok := n.SupportsEIP868(); ok {
+			eip868 = sql.NullInt64{Int64: boolToInt(supported), Valid: true}
+		}
+
+		var dialAttempts sql.NullInt64
+		if info.DialAttempts != 0 {
+			dialAttempts = sql.NullInt64{Int64: int64(info.DialAttempts), Valid: true}
+		}
+
+		// The snap columns stay empty for nodes which were not probed.
+		var snapAnswered, snapLatency, snapResponseSize sql.NullInt64
+		if info.Snap != nil {
+			snapAnswered = sql.NullInt64{Valid: true}
+			if info.Snap.Answered {
+				snapAnswered.Int64 = 1
+				snapLatency = sql.NullInt64{Int64: info.Snap.Latency.Milliseconds(), Valid: true}
+				snapResponseSize = sql.NullInt64{Int64: int64(info.Snap.ResponseSize), Valid: true}
+			}
+		}
+
+		var failurePhase, failureKind string
+		var discReason sql.NullInt64
+		if n.Failure != nil {
+			failurePhase, failureKind = n.Failure.Phase, n.Failure.Kind
+			if n.Failure.Kind == common.FailKindDisconnect {
+				discReason = sql.NullInt64{Int64: int64(n.Failure.Reason), Valid: true}
+			}
+		}
+
+		// The identity announced in the record (EIP-7636) is stored next to
+		// the one of the Hello, so nodes we cannot dial are identified too.
+		var enrClient string
+		if client, ok := enrattr.ReadClient(n.N.Record()); ok {
+			enrClient = client.String()
+		}
+
+		var label networks.Label
+		if chains != nil {
+			label = chains.LabelNode(n)
+		}
+
 		var caps string
 		for _, c := range info.Capabilities {
 			caps = fmt.Sprintf("%v, %v", caps, c.String())
@@ -98,10 +211,17 @@ func UpdateNodes(db *sql.DB, geoipDB *geoip2.Reader, nodes []common.NodeJSON) er
 			pk = fmt.Sprintf("X: %v, Y: %v", n.N.Pubkey().X.String(), n.N.Pubkey().Y.String())
 		}
 
+		// Prefer the address we actually connected to, it may be of the
+		// other address family.
+		ip := n.N.IPAddr()
+		if info.DialAddr.IsValid() {
+			ip = info.DialAddr.Addr()
+		}
+
 		var country, city, loc string
 		if geoipDB != nil {
 			// parse GeoIp info
-			ipRecord, err := geoipDB.City(n.N.IP())
+			ipRecord, err := geoipDB.City(net.IP(ip.AsSlice()))
 			if err != nil {
 				return err
 			}
@@ -111,6 +231,19 @@ func UpdateNodes(db *sql.DB, geoipDB *geoip2.Reader, nodes []common.NodeJSON) er
 				fmt.Sprintf("%v,%v", ipRecord.Location.Latitude, ipRecord.Location.Longitude)
 		}
 
+		var asn sql.NullInt64
+		var asnOrg string
+		if asnDB != nil && ip.IsValid() {
+			asnRecord, err := asnDB.ASN(net.IP(ip.AsSlice()))
+			if err != nil {
+				return err
+			}
+			if asnRecord.AutonomousSystemNumber != 0 {
+				asn = sql.NullInt64{Int64: int64(asnRecord.AutonomousSystemNumber), Valid: true}
+				asnOrg = asnRecord.AutonomousSystemOrganization
+			}
+		}
+
 		_, err = stmt.Exec(
 			n.N.ID().String(),
 			now.String(),
@@ -123,7 +256,7 @@ func UpdateNodes(db *sql.DB, geoipDB *geoip2.Reader, nodes []common.NodeJSON) er
 			info.Blockheight,
 			info.TotalDifficulty.String(),
 			info.HeadHash.String(),
-			n.N.IP().String(),
+			ip.String(),
 			country,
 			city,
 			loc,
@@ -132,15 +265,175 @@ func UpdateNodes(db *sql.DB, geoipDB *geoip2.Reader, nodes []common.NodeJSON) er
 			n.Seq,
 			n.Score,
 			connType,
+			ipFamily(ip),
+			earliestBlock,
+			latestBlock,
+			headTime,
+			info.ForkCompat,
+			snapAnswered,
+			snapLatency,
+			snapResponseSize,
+			failurePhase,
+			failureKind,
+			discReason,
+			asn,
+			asnOrg,
+			milliseconds(timings.RequestENR),
+			milliseconds(timings.Connect),
+			milliseconds(timings.Handshake),
+			milliseconds(timings.Hello),
+			milliseconds(timings.Status),
+			dialAttempts,
+			n.DiscoveryProtocols(),
+			discoveryAlive(n, common.DiscV4),
+			discoveryAlive(n, common.DiscV5),
+			eip868,
+			n.ENRLess,
+			enrClient,
+			label.Chain,
+			label.Stage,
 		)
 		if err != nil {
 			return err
 		}
+
+		// The records of nodes without ENR only hold their endpoint.
+		if n.ENRLess {
+			continue
+		}
+		for _, attr := range enrattr.Decode(n.N.Record()) {
+			_, err = attrStmt.Exec(
+				n.N.ID().String(),
+				now.String(),
+				n.N.Seq(),
+				attr.Key,
+				attr.Value,
+				hex.EncodeToString(attr.Raw),
+				attr.Decoded,
+			)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return tx.Commit()
 }
 
+// InsertEstimates writes the size estimates of the round started at the
+// given time.
+func InsertEstimates(db *sql.DB, round time.Time, estimates []estimate.SizeEstimate) error {
+	if len(estimates) == 0 {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(
+		`INSERT INTO estimates(
+			Round,
+			Method,
+			Client,
+			NetworkID,
+			First,
+			Second,
+			Both,
+			Size,
+			Lower,
+			Upper
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, e := range estimates {
+		_, err = stmt.Exec(
+			round.Format(time.RFC3339),
+			e.Method,
+			e.Client,
+			e.NetworkID,
+			e.First,
+			e.Second,
+			e.Both,
+			e.Size,
+			e.Lower,
+			e.Upper,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// InsertChainCounts writes the number of nodes of every chain and fork stage
+// in the round started at the given time.
+func InsertChainCounts(db *sql.DB, round time.Time, counts map[networks.Label]int) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO chains(Round, Chain, ForkStage, Nodes) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for label, n := range counts {
+		_, err = stmt.Exec(round.Format(time.RFC3339), label.Chain, label.Stage, n)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// discoveryAlive returns whether the node answered the last ENR request of
+// the protocol, or NULL if the protocol didn't check it.
+func discoveryAlive(n common.NodeJSON, protocol string) sql.NullInt64 {
+	d, ok := n.Discovery[protocol]
+	if !ok || d.LastCheck.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: boolToInt(d.Alive()), Valid: true}
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// milliseconds returns the duration in milliseconds, or NULL if it is zero.
+func milliseconds(d time.Duration) sql.NullInt64 {
+	if d == 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: d.Milliseconds(), Valid: true}
+}
+
+func ipFamily(ip netip.Addr) string {
+	switch {
+	case !ip.IsValid():
+		return ""
+	case ip.Is4() || ip.Is4In6():
+		return "IPv4"
+	default:
+		return "IPv6"
+	}
+}
+
 func CreateDB(db *sql.DB) error {
 	sqlStmt := `
 	CREATE TABLE nodes (
@@ -164,10 +457,158 @@ func CreateDB(db *sql.DB) error {
 		Seq             NUMBER,
 		Score           NUMBER,
 		ConnType        TEXT,
+		IPFamily        TEXT,
+		EarliestBlock   NUMBER,
+		LatestBlock     NUMBER,
+		HeadTime        NUMBER,
+		ForkCompat      TEXT,
+		SnapAnswered    NUMBER,
+		SnapLatency     NUMBER,
+		SnapResponseSize NUMBER,
+		FailurePhase    TEXT,
+		FailureKind     TEXT,
+		DiscReason      NUMBER,
+		ASN             NUMBER,
+		ASNOrg          TEXT,
+		ENRLatency      NUMBER,
+		ConnectLatency  NUMBER,
+		HandshakeLatency NUMBER,
+		HelloLatency    NUMBER,
+		StatusLatency   NUMBER,
+		DialAttempts    NUMBER,
+		DiscProtocols   TEXT,
+		DiscV4Alive     NUMBER,
+		DiscV5Alive     NUMBER,
+		EIP868          NUMBER,
+		ENRLess         NUMBER,
+		ENRClient       TEXT,
+		Chain           TEXT,
+		ForkStage       TEXT,
 		PRIMARY KEY (ID, Now)
 	);
 	DELETE FROM nodes;
-	`
+	` + createEstimatesTable + createENRAttrsTable + createChainsTable
 	_, err := db.Exec(sqlStmt)
 	return err
 }
+
+// createEstimatesTable creates the table of the size estimates, which was
+// added after the nodes table.
+const createEstimatesTable = `
+	CREATE TABLE IF NOT EXISTS estimates (
+		Round     TEXT NOT NULL,
+		Method    TEXT NOT NULL,
+		Client    TEXT NOT NULL,
+		NetworkID NUMBER NOT NULL,
+		First     NUMBER,
+		Second    NUMBER,
+		Both      NUMBER,
+		Size      REAL,
+		Lower     REAL,
+		Upper     REAL,
+		PRIMARY KEY (Round, Method, Client, NetworkID)
+	);
+`
+
+// createENRAttrsTable creates the table of the key/value pairs of the node
+// records, which was added after the nodes table.
+const createENRAttrsTable = `
+	CREATE TABLE IF NOT EXISTS ENRAttrs (
+		ID      TEXT NOT NULL,
+		Now     TEXT NOT NULL,
+		Seq     NUMBER,
+		Key     TEXT NOT NULL,
+		Value   TEXT,
+		Raw     TEXT,
+		Decoded NUMBER,
+		PRIMARY KEY (ID, Now, Key)
+	);
+`
+
+// createChainsTable creates the table of the number of nodes of every chain
+// and fork stage in a crawl round, which was added after the nodes table.
+const createChainsTable = `
+	CREATE TABLE IF NOT EXISTS chains (
+		Round     TEXT NOT NULL,
+		Chain     TEXT NOT NULL,
+		ForkStage TEXT NOT NULL,
+		Nodes     NUMBER,
+		PRIMARY KEY (Round, Chain, ForkStage)
+	);
+`
+
+// addedColumns are the columns which were added to the nodes table after
+// its initial version. UpgradeDB adds them to older databases.
+var addedColumns = []struct{ name, typ string }{
+	{"IPFamily", "TEXT"},
+	{"EarliestBlock", "NUMBER"},
+	{"LatestBlock", "NUMBER"},
+	{"HeadTime", "NUMBER"},
+	{"ForkCompat", "TEXT"},
+	{"SnapAnswered", "NUMBER"},
+	{"SnapLatency", "NUMBER"},
+	{"SnapResponseSize", "NUMBER"},
+	{"FailurePhase", "TEXT"},
+	{"FailureKind", "TEXT"},
+	{"DiscReason", "NUMBER"},
+	{"ASN", "NUMBER"},
+	{"ASNOrg", "TEXT"},
+	{"ENRLatency", "NUMBER"},
+	{"ConnectLatency", "NUMBER"},
+	{"HandshakeLatency", "NUMBER"},
+	{"HelloLatency", "NUMBER"},
+	{"StatusLatency", "NUMBER"},
+	{"DialAttempts", "NUMBER"},
+	{"DiscProtocols", "TEXT"},
+	{"DiscV4Alive", "NUMBER"},
+	{"DiscV5Alive", "NUMBER"},
+	{"EIP868", "NUMBER"},
+	{"ENRLess", "NUMBER"},
+	{"ENRClient", "TEXT"},
+	{"Chain", "TEXT"},
+	{"ForkStage", "TEXT"},
+}
+
+// UpgradeDB adds any tables and columns missing in a database created by an
+// older version of the crawler.
+func UpgradeDB(db *sql.DB) error {
+	if _, err := db.Exec(createEstimatesTable); err != nil {
+		return fmt.Errorf("error creating estimates table: %w", err)
+	}
+	if _, err := db.Exec(createENRAttrsTable); err != nil {
+		return fmt.Errorf("error creating ENR attributes table: %w", err)
+	}
+	if _, err := db.Exec(createChainsTable); err != nil {
+		return fmt.Errorf("error creating chains table: %w", err)
+	}
+
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('nodes')`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existing := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, col := range addedColumns {
+		if existing[col.name] {
+			continue
+		}
+		log.Info("Adding column to crawler db", "column", col.name)
+		_, err := db.Exec(fmt.Sprintf("ALTER TABLE nodes ADD COLUMN %s %s", col.name, col.typ))
+		if err != nil {
+			return fmt.Errorf("error adding column %s: %w", col.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+// Package enrattr decodes the key/value pairs of node records (EIP-778).
+package enrattr
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Attr is a key/value pair of a node record.
+type Attr struct {
+	Key string `json:"key"`
+	// Value is the decoded value, or the hex encoded raw value if the key is
+	// unknown or the value cannot be decoded.
+	Value string `json:"value"`
+	// Raw is the RLP encoded value.
+	Raw hexutil.Bytes `json:"raw"`
+	// Decoded reports whether Value was decoded by a formatter.
+	Decoded bool `json:"decoded"`
+}
+
+// Decode returns the key/value pairs of the record, in the order of the
+// record, which is sorted by key.
+func Decode(r *enr.Record) []Attr {
+	elems := r.AppendElements(nil)[1:]
+	attrs := make([]Attr, 0, len(elems)/2)
+	for i := 0; i+1 < len(elems); i += 2 {
+		key, raw := elems[i].(string), elems[i+1].(rlp.RawValue)
+		attrs = append(attrs, Format(key, raw))
+	}
+	return attrs
+}
+
+// Format decodes the value of the given key.
+func Format(key string, raw rlp.RawValue) Attr {
+	attr := Attr{Key: key, Raw: hexutil.Bytes(raw)}
+	if format, ok := attrFormatters[key]; ok {
+		if v, ok := format(raw); ok {
+			attr.Value, attr.Decoded = v, true
+			return attr
+		}
+	}
+	attr.Value, _ = formatAttrRaw(raw)
+	return attr
+}
+
+// attrFormatters contains formatting functions for well-known ENR keys.
+var attrFormatters = map[string]func(rlp.RawValue) (string, bool){
+	"id":        formatAttrString,
+	"ip":        formatAttrIP,
+	"ip6":       formatAttrIP,
+	"tcp":       formatAttrUint,
+	"tcp6":      formatAttrUint,
+	"udp":       formatAttrUint,
+	"udp6":      formatAttrUint,
+	"quic":      formatAttrUint,
+	"quic6":     formatAttrUint,
+	"secp256k1": formatAttrHex,
+	"eth":       formatAttrEth,
+	"eth2":      formatAttrEth2,
+	"attnets":   formatAttrBitvector,
+	"syncnets":  formatAttrBitvector,
+	"snap":      formatAttrList,
+	"les":       formatAttrList,
+	"client":    formatAttrClient,
+}
+
+func formatAttrRaw(v rlp.RawValue) (string, bool) {
+	s := hex.EncodeToString(v)
+	return s, true
+}
+
+func formatAttrString(v rlp.RawValue) (string, bool) {
+	content, _, err := rlp.SplitString(v)
+	return strconv.Quote(string(content)), err == nil
+}
+
+func formatAttrIP(v rlp.RawValue) (string, bool) {
+	content, _, err := rlp.SplitString(v)
+	if err != nil || len(content) != 4 && len(content) != 16 {
+		return "", false
+	}
+	return net.IP(content).String(), true
+}
+
+func formatAttrUint(v rlp.RawValue) (string, bool) {
+	var x uint64
+	if err := rlp.DecodeBytes(v, &x); err != nil {
+		return "", false
+	}
+	return strconv.FormatUint(x, 10), true
+}
+
+func formatAttrHex(v rlp.RawValue) (string, bool) {
+	content, _, err := rlp.SplitString(v)
+	if err != nil {
+		return "", false
+	}
+	return "0x" + hex.EncodeToString(content), true
+}
+
+// Eth is the "eth" entry of execution layer nodes, the fork ID (EIP-2124)
+// of the chain they are on.
+type Eth struct {
+	ForkID forkid.ID
+	Rest   []rlp.RawValue `rlp:"tail"`
+}
+
+func (Eth) ENRKey() string { return "eth" }
+
+// formatAttrEth formats the fork ID of the eth protocol (EIP-2124).
+func formatAttrEth(v rlp.RawValue) (string, bool) {
+	var entry Eth
+	if err := rlp.DecodeBytes(v, &entry); err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("hash=0x%x next=%d", entry.ForkID.Hash, entry.ForkID.Next), true
+}
+
+// formatAttrEth2 formats the SSZ encoded ENRForkID of the consensus layer.
+func formatAttrEth2(v rlp.RawValue) (string, bool) {
+	content, _, err := rlp.SplitString(v)
+	if err != nil || len(content) != 16 {
+		return "", false
+	}
+	return fmt.Sprintf("digest=0x%x next_version=0x%x next_epoch=%d",
+		content[:4], content[4:8], binary.LittleEndian.Uint64(content[8:])), true
+}
+
+// formatAttrBitvector formats the subnet bitfields of the consensus layer,
+// with the number of subnets.
+func formatAttrBitvector(v rlp.RawValue) (string, bool) {
+	content, _, err := rlp.SplitString(v)
+	if err != nil {
+		return "", false
+	}
+	var n int
+	for _, b := range content {
+		n += bits.OnesCount8(b)
+	}
+	return fmt.Sprintf("0x%x (%d subnets)", content, n), true
+}
+
+// formatAttrList formats the entries of protocols which carry no data, or
+// data which isn't interpreted, as the hex encoded list elements.
+func formatAttrList(v rlp.RawValue) (string, bool) {
+	var elems []rlp.RawValue
+	if err := rlp.DecodeBytes(v, &elems); err != nil {
+		return "", false
+	}
+	s := make([]string, len(elems))
+	for i, e := range elems {
+		s[i] = hex.EncodeToString(e)
+	}
+	return "[" + strings.Join(s, ", ") + "]", true
+}
+
+// Client is the "client" entry of EIP-7636, the name, version and optional
+// build of the client software.
+type Client struct {
+	Name    string
+	Version string
+	Build   string
+}
+
+func (Client) ENRKey() string { return "client" }
+
+// String returns the fields of the entry joined with "/", which is how the
+// identity is stored and what vparser.ParseENRClient expects.
+func (c Client) String() string {
+	if c.Build == "" {
+		return c.Name + "/" + c.Version
+	}
+	return c.Name + "/" + c.Version + "/" + c.Build
+}
+
+// EncodeRLP implements rlp.Encoder. The build is omitted if it is empty.
+func (c Client) EncodeRLP(w io.Writer) error {
+	fields := []string{c.Name, c.Version}
+	if c.Build != "" {
+		fields = append(fields, c.Build)
+	}
+	return rlp.Encode(w, fields)
+}
+
+// DecodeRLP implements rlp.Decoder. Fields after the build are ignored.
+func (c *Client) DecodeRLP(s *rlp.Stream) error {
+	var fields []string
+	if err := s.Decode(&fields); err != nil {
+		return err
+	}
+	if len(fields) < 2 || fields[0] == "" {
+		return errInvalidClient
+	}
+	c.Name, c.Version, c.Build = fields[0], fields[1], ""
+	if len(fields) > 2 {
+		c.Build = fields[2]
+	}
+	return nil
+}
+
+var errInvalidClient = errors.New("client entry without name and version")
+
+// ReadClient returns the "client" entry of the record.
+func ReadClient(r *enr.Record) (Client, bool) {
+	var c Client
+	if err := r.Load(&c); err != nil {
+		return Client{}, false
+	}
+	return c, true
+}
+
+// formatAttrClient formats the client name, version and build of EIP-7636.
+func formatAttrClient(v rlp.RawValue) (string, bool) {
+	var fields []string
+	if err := rlp.DecodeBytes(v, &fields); err != nil || len(fields) == 0 {
+		return "", false
+	}
+	return strings.Join(fields, "/"), true
+}
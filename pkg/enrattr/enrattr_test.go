@@ -0,0 +1,107 @@
+package enrattr
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestDecode(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+	var r enr.Record
+	r.Set(enr.IPv4(net.IPv4(10, 0, 0, 1)))
+	r.Set(enr.IPv6(net.ParseIP("2001:db8::1")))
+	r.Set(enr.UDP(30303))
+	r.Set(enr.QUIC(9001))
+	r.Set(Eth{ForkID: forkid.ID{Hash: [4]byte{0xfc, 0x64, 0xec, 0x04}, Next: 1150000}})
+	r.Set(enr.WithEntry("eth2", []byte{1, 2, 3, 4, 5, 6, 7, 8, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}))
+	r.Set(enr.WithEntry("attnets", []byte{0x03, 0, 0, 0, 0, 0, 0, 0x80}))
+	r.Set(enr.WithEntry("snap", []any{}))
+	r.Set(enr.WithEntry("client", []string{"Geth", "1.15.9", "abc"}))
+	r.Set(enr.WithEntry("foo", uint(42)))
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct {
+		value   string
+		decoded bool
+	}{
+		"attnets": {"0x0300000000000080 (3 subnets)", true},
+		"client":  {"Geth/1.15.9/abc", true},
+		"eth":     {"hash=0xfc64ec04 next=1150000", true},
+		"eth2":    {"digest=0x01020304 next_version=0x05060708 next_epoch=18446744073709551615", true},
+		"foo":     {"2a", false},
+		"id":      {`"v4"`, true},
+		"ip":      {"10.0.0.1", true},
+		"ip6":     {"2001:db8::1", true},
+		"quic":    {"9001", true},
+		"snap":    {"[]", true},
+		"udp":     {"30303", true},
+	}
+	attrs := Decode(&r)
+	seen := make(map[string]bool)
+	for _, a := range attrs {
+		seen[a.Key] = true
+		if a.Key == "secp256k1" {
+			if !a.Decoded || len(a.Value) != 2+66 {
+				t.Errorf("wrong public key %q", a.Value)
+			}
+			continue
+		}
+		w, ok := want[a.Key]
+		if !ok {
+			t.Errorf("unexpected key %q", a.Key)
+			continue
+		}
+		if a.Value != w.value || a.Decoded != w.decoded {
+			t.Errorf("%s: got %q (decoded %t), want %q (decoded %t)", a.Key, a.Value, a.Decoded, w.value, w.decoded)
+		}
+	}
+	for k := range want {
+		if !seen[k] {
+			t.Errorf("missing key %q", k)
+		}
+	}
+}
+
+func TestFormatInvalid(t *testing.T) {
+	// A malformed value of a known key is returned as hex.
+	a := Format("ip", rlp.RawValue{0x83, 1, 2, 3})
+	if a.Decoded || a.Value != "83010203" {
+		t.Errorf("got %+v", a)
+	}
+}
+
+func TestReadClient(t *testing.T) {
+	tests := []struct {
+		entry enr.Entry
+		want  Client
+		ok    bool
+	}{
+		{Client{Name: "Geth", Version: "1.15.9", Build: "abc"}, Client{"Geth", "1.15.9", "abc"}, true},
+		{Client{Name: "Reth", Version: "v1.3.12"}, Client{"Reth", "v1.3.12", ""}, true},
+		{enr.WithEntry("client", []string{"Nethermind", "1.31.0", "", "extra"}), Client{"Nethermind", "1.31.0", ""}, true},
+		{enr.WithEntry("client", []string{"Geth"}), Client{}, false},
+		{enr.WithEntry("client", uint(1)), Client{}, false},
+	}
+	for _, tt := range tests {
+		var r enr.Record
+		r.Set(tt.entry)
+		got, ok := ReadClient(&r)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%v: got %+v (ok %t), want %+v (ok %t)", tt.entry, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	var r enr.Record
+	if _, ok := ReadClient(&r); ok {
+		t.Error("client read from record without entry")
+	}
+}
@@ -0,0 +1,159 @@
+// Package estimate estimates the size of the network from overlapping
+// crawls, using capture–recapture.
+package estimate
+
+import (
+	"math"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/node-crawler/pkg/common"
+	"github.com/ethereum/node-crawler/pkg/vparser"
+)
+
+// Methods of pairing two crawls.
+const (
+	// MethodDiscovery pairs the discv4 and discv5 crawls of a round.
+	MethodDiscovery = "discovery"
+	// MethodRounds pairs two consecutive rounds.
+	MethodRounds = "rounds"
+)
+
+// z95 is the standard normal quantile of a two-sided 95% interval.
+const z95 = 1.959964
+
+// Estimate is the estimated size of a population from two captures.
+type Estimate struct {
+	// First and Second are the sizes of the captures, Both the number of
+	// individuals in both.
+	First, Second, Both int
+	// Size is the estimated size, and Lower and Upper the bounds of its 95%
+	// confidence interval.
+	Size, Lower, Upper float64
+}
+
+// LincolnPetersen estimates the size of a population from two captures with
+// Chapman's bias-corrected form of the Lincoln–Petersen estimator. The
+// confidence interval uses Seber's variance, and the lower bound is never
+// below the number of individuals actually seen.
+func LincolnPetersen(first, second, both int) Estimate {
+	var (
+		n1, n2, m = float64(first), float64(second), float64(both)
+		seen      = n1 + n2 - m
+	)
+	size := (n1+1)*(n2+1)/(m+1) - 1
+	variance := (n1 + 1) * (n2 + 1) * (n1 - m) * (n2 - m) / ((m + 1) * (m + 1) * (m + 2))
+	margin := z95 * math.Sqrt(variance)
+
+	return Estimate{
+		First:  first,
+		Second: second,
+		Both:   both,
+		Size:   size,
+		Lower:  math.Max(size-margin, seen),
+		Upper:  math.Max(size+margin, seen),
+	}
+}
+
+// SizeEstimate is the estimated number of nodes of a client in a network.
+type SizeEstimate struct {
+	Method string
+	// Client is the name of the client, "unknown" for nodes without client
+	// info, and "all" for all nodes of the network.
+	Client string
+	// NetworkID is zero for nodes which didn't send their status.
+	NetworkID uint64
+	Estimate
+}
+
+// Group is the client and network of a node.
+type Group struct {
+	Client    string
+	NetworkID uint64
+}
+
+// GroupOf returns the group of the node. Nodes without client info are
+// grouped as "unknown" in network zero.
+func GroupOf(n common.NodeJSON) Group {
+	g := Group{Client: "unknown"}
+	if n.Info == nil {
+		return g
+	}
+	if parsed := vparser.ParseVersionString(n.Info.ClientType); parsed != nil && parsed.Name != "" {
+		g.Client = parsed.Name
+	}
+	g.NetworkID = n.Info.NetworkID
+	return g
+}
+
+// Capture is the set of nodes seen by a crawl, with their groups.
+type Capture map[enode.ID]Group
+
+// Compare estimates the size of every group, and of every network as a
+// whole, from two captures. A node seen by both is counted in its group of
+// the second capture. The estimates are sorted by network, then client.
+func Compare(method string, first, second Capture) []SizeEstimate {
+	type counts struct{ first, second, both int }
+	groups := make(map[Group]*counts)
+	add := func(g Group, inFirst, inSecond bool) {
+		for _, g := range []Group{g, {Client: "all", NetworkID: g.NetworkID}} {
+			c := groups[g]
+			if c == nil {
+				c = new(counts)
+				groups[g] = c
+			}
+			if inFirst {
+				c.first++
+			}
+			if inSecond {
+				c.second++
+			}
+			if inFirst && inSecond {
+				c.both++
+			}
+		}
+	}
+	for id, g := range second {
+		_, inFirst := first[id]
+		add(g, inFirst, true)
+	}
+	for id, g := range first {
+		if _, ok := second[id]; !ok {
+			add(g, true, false)
+		}
+	}
+
+	estimates := make([]SizeEstimate, 0, len(groups))
+	for g, c := range groups {
+		estimates = append(estimates, SizeEstimate{
+			Method:    method,
+			Client:    g.Client,
+			NetworkID: g.NetworkID,
+			Estimate:  LincolnPetersen(c.first, c.second, c.both),
+		})
+	}
+	sort.Slice(estimates, func(i, j int) bool {
+		if estimates[i].NetworkID != estimates[j].NetworkID {
+			return estimates[i].NetworkID < estimates[j].NetworkID
+		}
+		return estimates[i].Client < estimates[j].Client
+	})
+	return estimates
+}
+
+// Rounds estimates the size of the network from consecutive crawl rounds.
+// The zero value is ready to use.
+type Rounds struct {
+	prev Capture
+}
+
+// Next compares the capture of a round with the one of the previous round,
+// and keeps it for the next. There are no estimates for the first round.
+func (r *Rounds) Next(c Capture) []SizeEstimate {
+	prev := r.prev
+	r.prev = c
+	if prev == nil {
+		return nil
+	}
+	return Compare(MethodRounds, prev, c)
+}
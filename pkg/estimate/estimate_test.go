@@ -0,0 +1,83 @@
+package estimate
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestLincolnPetersen(t *testing.T) {
+	tests := []struct {
+		first, second, both int
+		size, lower, upper  float64
+	}{
+		// Chapman: 101 * 81 / 41 - 1.
+		{100, 80, 40, 198.5366, 165.8516, 231.2216},
+		// Identical captures, the whole population was seen.
+		{50, 50, 50, 50, 50, 50},
+		// Disjoint captures have a wide interval.
+		{10, 10, 0, 120, 20, 272.4494},
+	}
+	for _, tt := range tests {
+		e := LincolnPetersen(tt.first, tt.second, tt.both)
+		if !near(e.Size, tt.size) || !near(e.Lower, tt.lower) || !near(e.Upper, tt.upper) {
+			t.Errorf("LincolnPetersen(%d, %d, %d) = %.4f [%.4f, %.4f], want %.4f [%.4f, %.4f]",
+				tt.first, tt.second, tt.both, e.Size, e.Lower, e.Upper, tt.size, tt.lower, tt.upper)
+		}
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-3
+}
+
+func TestCompare(t *testing.T) {
+	var (
+		geth = Group{Client: "geth", NetworkID: 1}
+		reth = Group{Client: "reth", NetworkID: 1}
+		ids  = make([]enode.ID, 6)
+	)
+	for i := range ids {
+		ids[i][0] = byte(i)
+	}
+	first := Capture{ids[0]: geth, ids[1]: geth, ids[2]: reth, ids[3]: Group{Client: "unknown"}}
+	// The unknown node was identified in the second capture.
+	second := Capture{ids[0]: geth, ids[2]: reth, ids[3]: geth, ids[4]: reth, ids[5]: reth}
+
+	got := Compare(MethodDiscovery, first, second)
+	want := []struct {
+		client              string
+		networkID           uint64
+		first, second, both int
+	}{
+		{"all", 1, 4, 5, 3},
+		{"geth", 1, 3, 2, 2},
+		{"reth", 1, 1, 3, 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d estimates, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		e := got[i]
+		if e.Method != MethodDiscovery || e.Client != w.client || e.NetworkID != w.networkID ||
+			e.First != w.first || e.Second != w.second || e.Both != w.both {
+			t.Errorf("estimate %d: got %+v, want %+v", i, e, w)
+		}
+	}
+}
+
+func TestRounds(t *testing.T) {
+	var (
+		r  Rounds
+		id enode.ID
+		c  = Capture{id: {Client: "geth", NetworkID: 1}}
+	)
+	if e := r.Next(c); e != nil {
+		t.Fatalf("estimates for the first round: %+v", e)
+	}
+	e := r.Next(c)
+	if len(e) != 2 || e[0].Method != MethodRounds || e[0].Both != 1 {
+		t.Fatalf("wrong estimates for the second round: %+v", e)
+	}
+}
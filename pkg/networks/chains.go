@@ -0,0 +1,211 @@
+package networks
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	gethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/forks"
+	"github.com/ethereum/node-crawler/pkg/common"
+	"github.com/ethereum/node-crawler/pkg/enrattr"
+)
+
+// UnknownChain is the chain of nodes which match no known chain.
+const UnknownChain = "unknown"
+
+// Chain is a known chain the nodes found by discovery are labeled with. The
+// discovery DHT is shared by many chains, not all of which can be crawled.
+type Chain struct {
+	Name        string          `json:"name"`
+	NetworkID   uint64          `json:"networkId"`
+	GenesisHash gethCommon.Hash `json:"genesisHash"`
+	// Forks are the fork IDs of the chain, in order.
+	Forks []Fork `json:"forks"`
+}
+
+// Fork is a fork ID of a chain, with the name of the fork it starts at.
+type Fork struct {
+	Name string        `json:"name"`
+	Hash hexutil.Bytes `json:"hash"`
+	Next uint64        `json:"next"`
+}
+
+// Label is the chain of a node and the fork it is at.
+type Label struct {
+	Chain string
+	// Stage is the fork the node is at, empty if the chain is unknown or
+	// the node is at a fork we don't know.
+	Stage string
+}
+
+// Registry classifies nodes by the known chains. The chains are tried in
+// order, so chains sharing fork IDs, like those of a network and its forks
+// before they split, are attributed to the first one.
+type Registry struct {
+	chains []Chain
+}
+
+// NewRegistry creates a registry of the networks the crawler knows how to
+// crawl, followed by the other known chains, and the chains of the given
+// file, if any. Chains in the file replace the built-in chains of the same
+// name.
+func NewRegistry(file string) (*Registry, error) {
+	r := new(Registry)
+	for _, name := range Names() {
+		p, err := Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		r.addChain(Chain{
+			Name:        p.Name,
+			NetworkID:   p.NetworkID,
+			GenesisHash: p.GenesisHash,
+			Forks:       p.Forks,
+		})
+	}
+	for _, c := range knownChains() {
+		r.addChain(c)
+	}
+	if file == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading chains: %w", err)
+	}
+	var chains []Chain
+	if err := json.Unmarshal(data, &chains); err != nil {
+		return nil, fmt.Errorf("error parsing chains: %w", err)
+	}
+	for _, c := range chains {
+		if c.Name == "" {
+			return nil, fmt.Errorf("chain without name in %s", file)
+		}
+		for _, f := range c.Forks {
+			if len(f.Hash) != 4 {
+				return nil, fmt.Errorf("chain %s: fork %q has a hash of %d bytes, want 4", c.Name, f.Name, len(f.Hash))
+			}
+		}
+		r.addChain(c)
+	}
+	return r, nil
+}
+
+func (r *Registry) addChain(c Chain) {
+	for i := range r.chains {
+		if r.chains[i].Name == c.Name {
+			r.chains[i] = c
+			return
+		}
+	}
+	r.chains = append(r.chains, c)
+}
+
+// Chains returns the known chains, in the order they are tried.
+func (r *Registry) Chains() []Chain {
+	return r.chains
+}
+
+// Classify returns the chain with the given fork ID, genesis hash and network
+// ID. Zero values are unknown, and match every chain. A chain matching the
+// genesis hash or network ID, but none of whose forks has the fork hash, is
+// only returned if no chain has it, with an empty stage.
+func (r *Registry) Classify(id forkid.ID, genesis gethCommon.Hash, networkID uint64) Label {
+	var (
+		known   = id.Hash != [4]byte{}
+		partial *Chain
+	)
+	if !known && genesis == (gethCommon.Hash{}) && networkID == 0 {
+		return Label{Chain: UnknownChain}
+	}
+	for i, c := range r.chains {
+		if genesis != (gethCommon.Hash{}) && c.GenesisHash != genesis {
+			continue
+		}
+		if networkID != 0 && c.NetworkID != networkID {
+			continue
+		}
+		if !known {
+			return Label{Chain: c.Name}
+		}
+		for _, f := range c.Forks {
+			if [4]byte(f.Hash) == id.Hash {
+				return Label{Chain: c.Name, Stage: f.Name}
+			}
+		}
+		// Only nodes which sent us the genesis hash are surely on the
+		// chain if they have an unknown fork hash.
+		if partial == nil && genesis != (gethCommon.Hash{}) {
+			partial = &r.chains[i]
+		}
+	}
+	if partial != nil {
+		return Label{Chain: partial.Name}
+	}
+	return Label{Chain: UnknownChain}
+}
+
+// LabelNode classifies the node by its status, or by the "eth" entry of its
+// record if we didn't get its status.
+func (r *Registry) LabelNode(n common.NodeJSON) Label {
+	if info := n.Info; info != nil && info.NetworkID != 0 {
+		return r.Classify(info.ForkID, info.Genesis, info.NetworkID)
+	}
+	var eth enrattr.Eth
+	if n.N != nil && n.N.Load(&eth) == nil {
+		return r.Classify(eth.ForkID, gethCommon.Hash{}, 0)
+	}
+	return Label{Chain: UnknownChain}
+}
+
+// forkAt returns the latest fork of the chain active at the given block and
+// time.
+func forkAt(c *params.ChainConfig, head, time uint64) forks.Fork {
+	num := new(big.Int).SetUint64(head)
+	switch {
+	case c.IsOsaka(num, time):
+		return forks.Osaka
+	case c.IsPrague(num, time):
+		return forks.Prague
+	case c.IsCancun(num, time):
+		return forks.Cancun
+	case c.IsShanghai(num, time):
+		return forks.Shanghai
+	case c.MergeNetsplitBlock != nil && head >= c.MergeNetsplitBlock.Uint64():
+		return forks.Paris
+	case c.IsGrayGlacier(num):
+		return forks.GrayGlacier
+	case c.IsArrowGlacier(num):
+		return forks.ArrowGlacier
+	case c.IsLondon(num):
+		return forks.London
+	case c.IsBerlin(num):
+		return forks.Berlin
+	case c.IsMuirGlacier(num):
+		return forks.MuirGlacier
+	case c.IsIstanbul(num):
+		return forks.Istanbul
+	case c.IsPetersburg(num):
+		return forks.Petersburg
+	case c.IsConstantinople(num):
+		return forks.Constantinople
+	case c.IsByzantium(num):
+		return forks.Byzantium
+	case c.IsEIP158(num):
+		return forks.SpuriousDragon
+	case c.IsEIP150(num):
+		return forks.TangerineWhistle
+	case c.IsDAOFork(num):
+		return forks.DAO
+	case c.IsHomestead(num):
+		return forks.Homestead
+	default:
+		return forks.Frontier
+	}
+}
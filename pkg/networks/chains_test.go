@@ -0,0 +1,198 @@
+package networks
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestMainnetForkNames(t *testing.T) {
+	p, err := Lookup("mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]string{
+		0:  "Frontier",
+		2:  "DAO",
+		6:  "Petersburg", // Constantinople was activated in the same block
+		13: "Shanghai",
+		14: "Cancun",
+	}
+	for i, name := range want {
+		if f := p.Forks[i]; f.Name != name || [4]byte(f.Hash) != p.ForkIDs[i].Hash {
+			t.Errorf("fork %d: got %s %x, want %s %x", i, f.Name, []byte(f.Hash), name, p.ForkIDs[i].Hash)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	r, err := NewRegistry("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mainnet, _ := Lookup("mainnet")
+	sepolia, _ := Lookup("sepolia")
+
+	var (
+		cancun  = forkid.ID{Hash: [4]byte{0x9f, 0x3d, 0x22, 0x54}, Next: 1746612311}
+		unknown = forkid.ID{Hash: [4]byte{0xde, 0xad, 0xbe, 0xef}}
+	)
+	tests := []struct {
+		name      string
+		id        forkid.ID
+		genesis   gethCommon.Hash
+		networkID uint64
+		want      Label
+	}{
+		{"status", cancun, params.MainnetGenesisHash, 1, Label{"mainnet", "Cancun"}},
+		{"record", cancun, gethCommon.Hash{}, 0, Label{"mainnet", "Cancun"}},
+		{"record-sepolia", sepolia.ForkIDs[2], gethCommon.Hash{}, 0, Label{"sepolia", "Shanghai"}},
+		{"future-fork", unknown, params.MainnetGenesisHash, 1, Label{"mainnet", ""}},
+		{"wrong-network", cancun, params.MainnetGenesisHash, 56, Label{UnknownChain, ""}},
+		{"unknown-record", unknown, gethCommon.Hash{}, 0, Label{UnknownChain, ""}},
+		{"nothing", forkid.ID{}, gethCommon.Hash{}, 0, Label{UnknownChain, ""}},
+		{"genesis-only", forkid.ID{}, mainnet.GenesisHash, 0, Label{"mainnet", ""}},
+		{"classic", forkid.ID{Hash: [4]byte{0xbe, 0x46, 0xd5, 0x7c}}, params.MainnetGenesisHash, 1, Label{"classic", "Spiral"}},
+		{"record-bsc", forkid.ID{Hash: [4]byte{0x09, 0x8d, 0x24, 0xac}}, gethCommon.Hash{}, 0, Label{"bsc", "Maxwell"}},
+		{"gnosis-future-fork", unknown, gethCommon.HexToHash("0x4f1dd23188aab3a76b463e4af801b52b1248ef073c648cbdc4c9333d3da79756"), 100, Label{"gnosis", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Classify(tt.id, tt.genesis, tt.networkID); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "chains.json")
+	chains := `[
+		{
+			"name": "testchain",
+			"networkId": 1337,
+			"genesisHash": "0x00000000000000000000000000000000000000000000000000000000000000aa",
+			"forks": [
+				{"name": "genesis", "hash": "0x01020304", "next": 100},
+				{"name": "upgrade", "hash": "0x05060708", "next": 0}
+			]
+		},
+		{
+			"name": "sepolia",
+			"networkId": 11155111,
+			"forks": [{"name": "renamed", "hash": "0x88cf81d9", "next": 0}]
+		}
+	]`
+	if err := os.WriteFile(file, []byte(chains), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRegistry(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, c := range r.Chains() {
+		names = append(names, c.Name)
+	}
+	if names[len(names)-1] != "testchain" {
+		t.Errorf("wrong chains %v", names)
+	}
+	if got := r.Classify(forkid.ID{Hash: [4]byte{5, 6, 7, 8}}, gethCommon.Hash{}, 0); got != (Label{"testchain", "upgrade"}) {
+		t.Errorf("wrong label for chain of the file: %+v", got)
+	}
+	if got := r.Classify(forkid.ID{Hash: [4]byte{0x88, 0xcf, 0x81, 0xd9}}, gethCommon.Hash{}, 0); got != (Label{"sepolia", "renamed"}) {
+		t.Errorf("network not replaced by the file: %+v", got)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	os.WriteFile(bad, []byte(`[{"name": "x", "forks": [{"name": "a", "hash": "0x0102"}]}]`), 0o644)
+	if _, err := NewRegistry(bad); err == nil {
+		t.Error("no error for a short fork hash")
+	}
+}
+
+func TestKnownChainForkIDs(t *testing.T) {
+	r, err := NewRegistry("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fork IDs announced by the nodes of these chains, the last of every
+	// chain is its current fork. The sources are cited in knownChains, the
+	// bsc, optimism and base ones and the last of polygon have no published
+	// vectors and are computed from the schedules.
+	tests := []struct {
+		chain string
+		fork  string
+		want  forkid.ID
+	}{
+		{"classic", "Frontier", forkid.ID{Hash: [4]byte{0xfc, 0x64, 0xec, 0x04}, Next: 1150000}},
+		{"classic", "Homestead", forkid.ID{Hash: [4]byte{0x97, 0xc2, 0xc3, 0x4c}, Next: 2500000}},
+		{"classic", "Agharta", forkid.ID{Hash: [4]byte{0x7b, 0xa2, 0x28, 0x82}, Next: 10500839}},
+		{"classic", "Phoenix", forkid.ID{Hash: [4]byte{0x90, 0x07, 0xbf, 0xcc}, Next: 11700000}},
+		{"classic", "Thanos", forkid.ID{Hash: [4]byte{0xdb, 0x63, 0xa1, 0xca}, Next: 13189133}},
+		{"classic", "Magneto", forkid.ID{Hash: [4]byte{0x0f, 0x6b, 0xf1, 0x87}, Next: 14525000}},
+		{"classic", "Mystique", forkid.ID{Hash: [4]byte{0x7f, 0xd1, 0xbb, 0x25}, Next: 19250000}},
+		{"classic", "Spiral", forkid.ID{Hash: [4]byte{0xbe, 0x46, 0xd5, 0x7c}}},
+		{"bsc", "Niels", forkid.ID{Hash: [4]byte{0x3f, 0x2e, 0x9a, 0xe4}, Next: 5184000}},
+		{"bsc", "Lorentz", forkid.ID{Hash: [4]byte{0x3b, 0xfc, 0x8c, 0x16}, Next: 1751250600}},
+		{"bsc", "Maxwell", forkid.ID{Hash: [4]byte{0x09, 0x8d, 0x24, 0xac}}},
+		{"polygon", "Petersburg", forkid.ID{Hash: [4]byte{0x0e, 0x07, 0xe7, 0x22}, Next: 3395000}},
+		{"polygon", "Istanbul", forkid.ID{Hash: [4]byte{0x27, 0x80, 0x65, 0x76}, Next: 14750000}},
+		{"polygon", "Berlin", forkid.ID{Hash: [4]byte{0x66, 0xe2, 0x6a, 0xdb}, Next: 23850000}},
+		{"polygon", "London", forkid.ID{Hash: [4]byte{0x4f, 0x2f, 0x71, 0xcc}, Next: 50523000}},
+		{"polygon", "Shanghai", forkid.ID{Hash: [4]byte{0xdc, 0x08, 0x86, 0x5c}, Next: 54876000}},
+		{"polygon", "Cancun", forkid.ID{Hash: [4]byte{0xf0, 0x97, 0xbc, 0x13}, Next: 73440256}},
+		{"polygon", "Prague", forkid.ID{Hash: [4]byte{0x22, 0xd5, 0x23, 0xb2}}},
+		{"gnosis", "Byzantium", forkid.ID{Hash: [4]byte{0xf6, 0x49, 0x09, 0xb1}, Next: 1604400}},
+		{"gnosis", "Constantinople", forkid.ID{Hash: [4]byte{0xfd, 0xe2, 0xd0, 0x83}, Next: 2508800}},
+		{"gnosis", "POSDAO", forkid.ID{Hash: [4]byte{0xb6, 0xe6, 0xcd, 0x81}, Next: 16101500}},
+		{"gnosis", "London", forkid.ID{Hash: [4]byte{0x01, 0x84, 0x79, 0xd3}, Next: 1690889660}},
+		{"gnosis", "Shanghai", forkid.ID{Hash: [4]byte{0x2e, 0xfe, 0x91, 0xba}, Next: 1710181820}},
+		{"gnosis", "Cancun", forkid.ID{Hash: [4]byte{0x13, 0x84, 0xdf, 0xc1}, Next: 1746021820}},
+		{"gnosis", "Prague", forkid.ID{Hash: [4]byte{0x2f, 0x09, 0x5d, 0x4a}}},
+		{"optimism", "Legacy", forkid.ID{Hash: [4]byte{0xca, 0xf5, 0x17, 0xed}, Next: 3950000}},
+		{"optimism", "Isthmus", forkid.ID{Hash: [4]byte{0x61, 0x16, 0x8c, 0xc9}}},
+		{"base", "Bedrock", forkid.ID{Hash: [4]byte{0x67, 0xda, 0x02, 0x60}, Next: 1704992401}},
+		{"base", "Isthmus", forkid.ID{Hash: [4]byte{0xa8, 0xd6, 0x75, 0x8f}}},
+		{"holesky", "London", forkid.ID{Hash: [4]byte{0xc6, 0x1a, 0x60, 0x98}, Next: 1696000704}},
+		{"holesky", "Cancun", forkid.ID{Hash: [4]byte{0x9b, 0x19, 0x2a, 0xd0}, Next: 1740434112}},
+		{"holesky", "Prague", forkid.ID{Hash: [4]byte{0xdf, 0xbd, 0x9b, 0xed}}},
+	}
+	chains := make(map[string]Chain)
+	for _, c := range r.Chains() {
+		chains[c.Name] = c
+	}
+	current := make(map[string]string)
+	for _, tt := range tests {
+		chain, ok := chains[tt.chain]
+		if !ok {
+			t.Fatalf("chain %s missing", tt.chain)
+		}
+		if tt.want.Next == 0 {
+			current[tt.chain] = tt.fork
+		}
+		var fork *Fork
+		for i := range chain.Forks {
+			if chain.Forks[i].Name == tt.fork {
+				fork = &chain.Forks[i]
+			}
+		}
+		if fork == nil {
+			t.Errorf("%s: fork %s missing", tt.chain, tt.fork)
+			continue
+		}
+		if [4]byte(fork.Hash) != tt.want.Hash || fork.Next != tt.want.Next {
+			t.Errorf("%s fork %s: got %x %d, want %x %d", tt.chain, tt.fork, []byte(fork.Hash), fork.Next, tt.want.Hash, tt.want.Next)
+		}
+	}
+	for _, chain := range knownChains() {
+		if last := chain.Forks[len(chain.Forks)-1]; last.Name != current[chain.Name] {
+			t.Errorf("%s: current fork %s not checked", chain.Name, last.Name)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package networks
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+
+	gethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// scheduledFork is a fork of a known chain, at the block number or timestamp
+// it activates at. Forks active at genesis are not scheduled, and forks
+// activating together are one.
+type scheduledFork struct {
+	name string
+	at   uint64
+}
+
+// knownChains returns the large chains sharing the discovery DHT with the
+// networks the crawler knows how to crawl.
+func knownChains() []Chain {
+	// The fork IDs of holesky are those of geth core/forkid/forkid_test.go.
+	holesky := core.DefaultHoleskyGenesisBlock()
+	_, holeskyForks := gatherForkIDs(holesky.Config, holesky.ToBlock())
+
+	// The L2 upgrades of the OP stack are the same on all its chains. The
+	// times are the hardfork activations of the superchain-registry
+	// (superchain/configs/mainnet/superchain.toml).
+	superchain := []scheduledFork{
+		{"Canyon", 1704992401},
+		{"Delta", 1708560000},
+		{"Ecotone", 1710374401},
+		{"Fjord", 1720627201},
+		{"Granite", 1726070401},
+		{"Holocene", 1736445601},
+		{"Isthmus", 1746806401},
+	}
+
+	return []Chain{
+		{
+			Name:        "holesky",
+			NetworkID:   params.HoleskyChainConfig.ChainID.Uint64(),
+			GenesisHash: params.HoleskyGenesisHash,
+			Forks:       holeskyForks,
+		},
+		// core-geth params/config_classic.go, the fork IDs are those of
+		// core-geth core/forkid/forkid_test.go.
+		newChain("classic", 1, params.MainnetGenesisHash, "Frontier",
+			scheduledFork{"Homestead", 1150000},
+			scheduledFork{"GasReprice", 2500000},
+			scheduledFork{"DieHard", 3000000},
+			scheduledFork{"Gotham", 5000000},
+			scheduledFork{"DefuseDifficultyBomb", 5900000},
+			scheduledFork{"Atlantis", 8772000},
+			scheduledFork{"Agharta", 9573000},
+			scheduledFork{"Phoenix", 10500839},
+			scheduledFork{"Thanos", 11700000},
+			scheduledFork{"Magneto", 13189133},
+			scheduledFork{"Mystique", 14525000},
+			scheduledFork{"Spiral", 19250000},
+		),
+		// bsc params/config.go (BSCChainConfig). Forks activating at the
+		// same block or time, like Berlin and London with Hertz, are one.
+		newChain("bsc", 56, gethCommon.HexToHash("0x0d21840abff46b96c84b2ac9e10e4f5cdaeb5693cb665db62a2f3b02d2d57b5b"), "Niels",
+			scheduledFork{"MirrorSync", 5184000},
+			scheduledFork{"Bruno", 13082000},
+			scheduledFork{"Euler", 18907621},
+			scheduledFork{"Nano", 21962149},
+			scheduledFork{"Moran", 22107423},
+			scheduledFork{"Gibbs", 23846001},
+			scheduledFork{"Planck", 27281024},
+			scheduledFork{"Luban", 29020050},
+			scheduledFork{"Plato", 30720096},
+			scheduledFork{"Hertz", 31302048},
+			scheduledFork{"HertzFix", 34140700},
+			scheduledFork{"Kepler", 1705996800},
+			scheduledFork{"Feynman", 1713419340},
+			scheduledFork{"Haber", 1718863500},
+			scheduledFork{"HaberFix", 1727316120},
+			scheduledFork{"Bohr", 1727317200},
+			scheduledFork{"Pascal", 1742436600},
+			scheduledFork{"Lorentz", 1745903100},
+			scheduledFork{"Maxwell", 1751250600},
+		),
+		// bor params/config.go (BorMainnetChainConfig), Prague is the
+		// Bhilai hardfork. The fork IDs up to Cancun are those of the
+		// forkid tests of erigon.
+		newChain("polygon", 137, gethCommon.HexToHash("0xa9c28ce2141b56c474f1dc504bee9b01eb1bd7d1a507580d5519d4437a97de1b"), "Petersburg",
+			scheduledFork{"Istanbul", 3395000},
+			scheduledFork{"Berlin", 14750000},
+			scheduledFork{"London", 23850000},
+			scheduledFork{"Shanghai", 50523000},
+			scheduledFork{"Cancun", 54876000},
+			scheduledFork{"Prague", 73440256},
+		),
+		// The gnosis chainspec of Nethermind (Chains/gnosis.json), the fork
+		// IDs are those of its ForkInfoTests.
+		newChain("gnosis", 100, gethCommon.HexToHash("0x4f1dd23188aab3a76b463e4af801b52b1248ef073c648cbdc4c9333d3da79756"), "Byzantium",
+			scheduledFork{"Constantinople", 1604400},
+			scheduledFork{"Petersburg", 2508800},
+			scheduledFork{"Istanbul", 7298030},
+			scheduledFork{"POSDAO", 9186425},
+			scheduledFork{"Berlin", 16101500},
+			scheduledFork{"London", 19040000},
+			scheduledFork{"Shanghai", 1690889660},
+			scheduledFork{"Cancun", 1710181820},
+			scheduledFork{"Prague", 1746021820},
+		),
+		// op-geth with the superchain-registry config of OP Mainnet, whose
+		// genesis is the legacy one. Bedrock also activates London.
+		newChain("optimism", 10, gethCommon.HexToHash("0x7ca38a1916c42007829c55e69d3e9a73265554b586a499015373241b8a3fa48b"), "Legacy",
+			append([]scheduledFork{
+				{"Berlin", 3950000},
+				{"Bedrock", 105235063},
+			}, superchain...)...,
+		),
+		// op-geth with the superchain-registry config of Base, which
+		// started with Bedrock.
+		newChain("base", 8453, gethCommon.HexToHash("0xf712aa9241cc24369b143cf6dce85f0902a9731e70d66818a3a5845b296c73dd"), "Bedrock",
+			superchain...,
+		),
+	}
+}
+
+// newChain returns the chain with the fork IDs of the given fork schedule,
+// computed as defined by EIP-2124.
+func newChain(name string, networkID uint64, genesis gethCommon.Hash, genesisFork string, schedule ...scheduledFork) Chain {
+	var (
+		c    = Chain{Name: name, NetworkID: networkID, GenesisHash: genesis}
+		hash = crc32.ChecksumIEEE(genesis[:])
+		fork = genesisFork
+	)
+	for _, f := range schedule {
+		c.Forks = append(c.Forks, Fork{Name: fork, Hash: binary.BigEndian.AppendUint32(nil, hash), Next: f.at})
+		hash = crc32.Update(hash, crc32.IEEETable, binary.BigEndian.AppendUint64(nil, f.at))
+		fork = f.name
+	}
+	c.Forks = append(c.Forks, Fork{Name: fork, Hash: binary.BigEndian.AppendUint32(nil, hash)})
+	return c
+}
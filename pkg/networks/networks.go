@@ -0,0 +1,140 @@
+// Package networks contains the profiles of the networks the crawler knows
+// how to crawl. A profile holds everything needed to take part in discovery
+// and the eth status handshake of a network. The Registry of known chains
+// labels the nodes found by discovery with the chain they are on.
+package networks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+type Profile struct {
+	Name      string
+	NetworkID uint64
+	Genesis   *core.Genesis
+	// GenesisHash is the hash of the genesis block.
+	GenesisHash common.Hash
+	Bootnodes   []string
+	// DNSRoots are enrtree:// URLs of EIP-1459 node lists for the network.
+	DNSRoots []string
+	// ForkIDs are all fork IDs of the network, from genesis to the last
+	// scheduled fork, in order. Forks are the same with the names of the
+	// forks.
+	ForkIDs []forkid.ID
+	Forks   []Fork
+}
+
+// registry contains the constructors of the known networks' profiles.
+// Profiles are created on demand, as computing genesis blocks is expensive.
+var registry = map[string]func() *Profile{
+	"mainnet": func() *Profile {
+		return newProfile("mainnet", params.MainnetChainConfig.ChainID.Uint64(), core.DefaultGenesisBlock(), params.MainnetBootnodes)
+	},
+	"sepolia": func() *Profile {
+		return newProfile("sepolia", params.SepoliaChainConfig.ChainID.Uint64(), core.DefaultSepoliaGenesisBlock(), params.SepoliaBootnodes)
+	},
+	"hoodi": func() *Profile {
+		return newProfile("hoodi", params.HoodiChainConfig.ChainID.Uint64(), core.DefaultHoodiGenesisBlock(), params.HoodiBootnodes)
+	},
+}
+
+func newProfile(name string, networkID uint64, genesis *core.Genesis, bootnodes []string) *Profile {
+	block := genesis.ToBlock()
+	p := &Profile{
+		Name:        name,
+		NetworkID:   networkID,
+		Genesis:     genesis,
+		GenesisHash: block.Hash(),
+		Bootnodes:   bootnodes,
+	}
+	p.ForkIDs, p.Forks = gatherForkIDs(genesis.Config, block)
+	if dns := params.KnownDNSNetwork(p.GenesisHash, "all"); dns != "" {
+		p.DNSRoots = []string{dns}
+	}
+	return p
+}
+
+// Names returns the names of all registered networks, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the profile of the registered network with the given name.
+func Lookup(name string) (*Profile, error) {
+	newFn, ok := registry[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown network %q, known networks: %s", name, strings.Join(Names(), ", "))
+	}
+	return newFn(), nil
+}
+
+// LoadGenesis creates a profile for a custom network from a genesis JSON
+// file. The network ID is taken from the chain ID of the genesis config.
+// Custom networks have no default bootnodes or DNS lists.
+func LoadGenesis(file string) (*Profile, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading genesis: %w", err)
+	}
+
+	var genesis core.Genesis
+	if err := json.Unmarshal(data, &genesis); err != nil {
+		return nil, fmt.Errorf("error parsing genesis: %w", err)
+	}
+	if genesis.Config == nil || genesis.Config.ChainID == nil {
+		return nil, fmt.Errorf("genesis %s has no chain ID", file)
+	}
+
+	return newProfile(file, genesis.Config.ChainID.Uint64(), &genesis, nil), nil
+}
+
+// ForkIDs returns the fork IDs of the chain with the given config and genesis
+// block, from genesis to the last scheduled fork.
+func ForkIDs(config *params.ChainConfig, genesis *types.Block) []forkid.ID {
+	ids, _ := gatherForkIDs(config, genesis)
+	return ids
+}
+
+// gatherForkIDs returns the fork IDs the network went through, or will go
+// through, starting with the one at genesis, and the same as named forks.
+func gatherForkIDs(config *params.ChainConfig, block *types.Block) ([]forkid.ID, []Fork) {
+	var (
+		head, time uint64
+		ids        []forkid.ID
+		forks      []Fork
+	)
+	for {
+		id := forkid.NewID(config, block, head, time)
+		ids = append(ids, id)
+		forks = append(forks, Fork{
+			Name: forkAt(config, head, time).String(),
+			Hash: id.Hash[:],
+			Next: id.Next,
+		})
+		if id.Next == 0 {
+			return ids, forks
+		}
+		// Block based forks come first. If passing the next block does not
+		// change the ID, the next fork is a timestamp.
+		if forkid.NewID(config, block, id.Next, time) != id {
+			head = id.Next
+		} else {
+			time = id.Next
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package networks
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestMainnetForkIDs(t *testing.T) {
+	p, err := Lookup("mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.GenesisHash != params.MainnetGenesisHash {
+		t.Fatalf("wrong genesis hash: %v", p.GenesisHash)
+	}
+	if len(p.DNSRoots) != 1 {
+		t.Fatalf("wrong DNS roots: %v", p.DNSRoots)
+	}
+
+	// Taken from the go-ethereum forkid tests.
+	want := []forkid.ID{
+		{Hash: [4]byte{0xfc, 0x64, 0xec, 0x04}, Next: 1150000},    // Unsynced
+		{Hash: [4]byte{0x97, 0xc2, 0xc3, 0x4c}, Next: 1920000},    // Homestead
+		{Hash: [4]byte{0x91, 0xd1, 0xf9, 0x48}, Next: 2463000},    // DAO
+		{Hash: [4]byte{0x7a, 0x64, 0xda, 0x13}, Next: 2675000},    // Tangerine Whistle
+		{Hash: [4]byte{0x3e, 0xdd, 0x5b, 0x10}, Next: 4370000},    // Spurious Dragon
+		{Hash: [4]byte{0xa0, 0x0b, 0xc3, 0x24}, Next: 7280000},    // Byzantium
+		{Hash: [4]byte{0x66, 0x8d, 0xb0, 0xaf}, Next: 9069000},    // Petersburg
+		{Hash: [4]byte{0x87, 0x9d, 0x6e, 0x30}, Next: 9200000},    // Istanbul
+		{Hash: [4]byte{0xe0, 0x29, 0xe9, 0x91}, Next: 12244000},   // Muir Glacier
+		{Hash: [4]byte{0x0e, 0xb4, 0x40, 0xf6}, Next: 12965000},   // Berlin
+		{Hash: [4]byte{0xb7, 0x15, 0x07, 0x7d}, Next: 13773000},   // London
+		{Hash: [4]byte{0x20, 0xc3, 0x27, 0xfc}, Next: 15050000},   // Arrow Glacier
+		{Hash: [4]byte{0xf0, 0xaf, 0xd0, 0xe3}, Next: 1681338455}, // Gray Glacier
+		{Hash: [4]byte{0xdc, 0xe9, 0x6c, 0x2d}, Next: 1710338135}, // Shanghai
+		{Hash: [4]byte{0x9f, 0x3d, 0x22, 0x54}, Next: 1746612311}, // Cancun
+	}
+	if len(p.ForkIDs) < len(want) {
+		t.Fatalf("too few fork IDs: got %d, want at least %d", len(p.ForkIDs), len(want))
+	}
+	for i, id := range want {
+		if p.ForkIDs[i] != id {
+			t.Errorf("fork ID %d mismatch: got %x, want %x", i, p.ForkIDs[i], id)
+		}
+	}
+}
+
+func TestLookupUnknown(t *testing.T) {
+	if _, err := Lookup("goerli"); err == nil {
+		t.Fatal("expected error for unknown network")
+	}
+}
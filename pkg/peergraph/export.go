@@ -0,0 +1,134 @@
+package peergraph
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Formats are the supported export formats, which are also the extensions
+// of their files.
+var Formats = []string{"graphml", "dot", "json"}
+
+// Write exports the graph in the given format. The client names of the nodes
+// are looked up with client, which may be nil.
+func Write(w io.Writer, format string, g *Graph, client func(enode.ID) string) error {
+	nodes, edges := g.Nodes(client), g.Edges()
+	switch format {
+	case "graphml":
+		return writeGraphML(w, nodes, edges)
+	case "dot":
+		return writeDOT(w, nodes, edges)
+	case "json":
+		return writeJSON(w, nodes, edges)
+	default:
+		return fmt.Errorf("unknown graph format %q", format)
+	}
+}
+
+func writeJSON(w io.Writer, nodes []Node, edges []Edge) error {
+	return json.NewEncoder(w).Encode(struct {
+		Nodes []Node `json:"nodes"`
+		Edges []Edge `json:"edges"`
+	}{nodes, edges})
+}
+
+func writeDOT(w io.Writer, nodes []Node, edges []Edge) error {
+	if _, err := fmt.Fprintln(w, "digraph peers {"); err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		_, err := fmt.Fprintf(w, "  %q [client=%q, enumerated=%t];\n", n.ID.String(), n.Client, n.Enumerated)
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range edges {
+		_, err := fmt.Fprintf(w, "  %q -> %q [protocol=%q, distance=%d];\n", e.From.String(), e.To.String(), e.Protocol, e.Distance)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// The GraphML document, see http://graphml.graphdrawing.org.
+type (
+	graphML struct {
+		XMLName xml.Name     `xml:"graphml"`
+		XMLNS   string       `xml:"xmlns,attr"`
+		Keys    []graphMLKey `xml:"key"`
+		Graph   graphMLGraph `xml:"graph"`
+	}
+	graphMLKey struct {
+		ID   string `xml:"id,attr"`
+		For  string `xml:"for,attr"`
+		Name string `xml:"attr.name,attr"`
+		Type string `xml:"attr.type,attr"`
+	}
+	graphMLGraph struct {
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	}
+	graphMLNode struct {
+		ID   string        `xml:"id,attr"`
+		Data []graphMLData `xml:"data"`
+	}
+	graphMLEdge struct {
+		Source string        `xml:"source,attr"`
+		Target string        `xml:"target,attr"`
+		Data   []graphMLData `xml:"data"`
+	}
+	graphMLData struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+)
+
+func writeGraphML(w io.Writer, nodes []Node, edges []Edge) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "client", For: "node", Name: "client", Type: "string"},
+			{ID: "enumerated", For: "node", Name: "enumerated", Type: "boolean"},
+			{ID: "protocol", For: "edge", Name: "protocol", Type: "string"},
+			{ID: "distance", For: "edge", Name: "distance", Type: "int"},
+		},
+		Graph: graphMLGraph{EdgeDefault: "directed"},
+	}
+	for _, n := range nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: n.ID.String(),
+			Data: []graphMLData{
+				{Key: "client", Value: n.Client},
+				{Key: "enumerated", Value: fmt.Sprint(n.Enumerated)},
+			},
+		})
+	}
+	for _, e := range edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: e.From.String(),
+			Target: e.To.String(),
+			Data: []graphMLData{
+				{Key: "protocol", Value: e.Protocol},
+				{Key: "distance", Value: fmt.Sprint(e.Distance)},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,146 @@
+// Package peergraph holds the directed graph of the routing tables of the
+// discovery network, and exports it as GraphML, DOT or JSON.
+package peergraph
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Edge means that To is in the routing table of From, in the bucket at the
+// given log-distance.
+type Edge struct {
+	From     enode.ID `json:"from"`
+	To       enode.ID `json:"to"`
+	Protocol string   `json:"protocol"`
+	Distance uint     `json:"distance"`
+}
+
+type edgeKey struct {
+	from, to enode.ID
+	protocol string
+}
+
+// Graph is a peer graph. It is safe for concurrent use.
+type Graph struct {
+	mu    sync.Mutex
+	edges map[edgeKey]Edge
+	// enumerated are the nodes whose routing table was queried.
+	enumerated map[enode.ID]struct{}
+}
+
+func New() *Graph {
+	return &Graph{
+		edges:      make(map[edgeKey]Edge),
+		enumerated: make(map[enode.ID]struct{}),
+	}
+}
+
+// Add records the nodes returned by a query of the routing table of from.
+func (g *Graph) Add(protocol string, from enode.ID, distance uint, to []*enode.Node) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.enumerated[from] = struct{}{}
+	for _, n := range to {
+		if n.ID() == from {
+			continue
+		}
+		key := edgeKey{from, n.ID(), protocol}
+		g.edges[key] = Edge{From: from, To: n.ID(), Protocol: protocol, Distance: distance}
+	}
+}
+
+// Merge adds the edges of other to the graph.
+func (g *Graph) Merge(other *Graph) {
+	edges, enumerated := other.snapshot()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, e := range edges {
+		g.edges[edgeKey{e.From, e.To, e.Protocol}] = e
+	}
+	for id := range enumerated {
+		g.enumerated[id] = struct{}{}
+	}
+}
+
+func (g *Graph) snapshot() ([]Edge, map[enode.ID]struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	edges := make([]Edge, 0, len(g.edges))
+	for _, e := range g.edges {
+		edges = append(edges, e)
+	}
+	enumerated := make(map[enode.ID]struct{}, len(g.enumerated))
+	for id := range g.enumerated {
+		enumerated[id] = struct{}{}
+	}
+	return edges, enumerated
+}
+
+// Edges returns the edges, sorted by source, destination and protocol.
+func (g *Graph) Edges() []Edge {
+	edges, _ := g.snapshot()
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if c := bytes.Compare(a.From[:], b.From[:]); c != 0 {
+			return c < 0
+		}
+		if c := bytes.Compare(a.To[:], b.To[:]); c != 0 {
+			return c < 0
+		}
+		return a.Protocol < b.Protocol
+	})
+	return edges
+}
+
+// Node is a vertex of the graph.
+type Node struct {
+	ID enode.ID `json:"id"`
+	// Client is the client name, if the node was dialed.
+	Client string `json:"client,omitempty"`
+	// Enumerated reports whether the routing table of the node was queried.
+	// Nodes which were not only have incoming edges.
+	Enumerated bool `json:"enumerated"`
+}
+
+// Nodes returns the nodes with at least one edge, sorted by ID. The client
+// names are looked up with client, which may be nil.
+func (g *Graph) Nodes(client func(enode.ID) string) []Node {
+	edges, enumerated := g.snapshot()
+
+	ids := make(map[enode.ID]struct{}, len(enumerated))
+	for id := range enumerated {
+		ids[id] = struct{}{}
+	}
+	for _, e := range edges {
+		ids[e.To] = struct{}{}
+	}
+
+	nodes := make([]Node, 0, len(ids))
+	for id := range ids {
+		_, ok := enumerated[id]
+		n := Node{ID: id, Enumerated: ok}
+		if client != nil {
+			n.Client = client(id)
+		}
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return bytes.Compare(nodes[i].ID[:], nodes[j].ID[:]) < 0
+	})
+	return nodes
+}
+
+// Len returns the number of enumerated nodes and the number of edges.
+func (g *Graph) Len() (enumerated, edges int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.enumerated), len(g.edges)
+}
@@ -0,0 +1,99 @@
+package peergraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func testNodes(t *testing.T, n int) []*enode.Node {
+	nodes := make([]*enode.Node, n)
+	for i := range nodes {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes[i] = enode.NewV4(&key.PublicKey, nil, 0, 0)
+	}
+	return nodes
+}
+
+func testGraph(t *testing.T) (*Graph, []*enode.Node) {
+	nodes := testNodes(t, 3)
+	v4, v5 := New(), New()
+	v4.Add("discv4", nodes[0].ID(), 256, []*enode.Node{nodes[1], nodes[2], nodes[0]})
+	v5.Add("discv5", nodes[0].ID(), 255, []*enode.Node{nodes[1]})
+	v5.Add("discv5", nodes[1].ID(), 256, []*enode.Node{nodes[2]})
+
+	g := New()
+	g.Merge(v4)
+	g.Merge(v5)
+	return g, nodes
+}
+
+func TestGraph(t *testing.T) {
+	g, nodes := testGraph(t)
+
+	if enumerated, edges := g.Len(); enumerated != 2 || edges != 4 {
+		t.Fatalf("wrong size: %d enumerated nodes, %d edges", enumerated, edges)
+	}
+	client := func(id enode.ID) string {
+		if id == nodes[0].ID() {
+			return "geth"
+		}
+		return ""
+	}
+	for _, n := range g.Nodes(client) {
+		wantEnumerated := n.ID != nodes[2].ID()
+		if n.Enumerated != wantEnumerated {
+			t.Errorf("node %v: enumerated %t, want %t", n.ID, n.Enumerated, wantEnumerated)
+		}
+		if n.ID == nodes[0].ID() && n.Client != "geth" {
+			t.Errorf("wrong client %q", n.Client)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	g, _ := testGraph(t)
+
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, g, nil); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		var nodes, edges int
+		switch format {
+		case "graphml":
+			var doc graphML
+			if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatalf("invalid GraphML: %v", err)
+			}
+			nodes, edges = len(doc.Graph.Nodes), len(doc.Graph.Edges)
+		case "dot":
+			nodes = strings.Count(buf.String(), "enumerated=")
+			edges = strings.Count(buf.String(), "->")
+		case "json":
+			var doc struct {
+				Nodes []Node
+				Edges []Edge
+			}
+			if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			nodes, edges = len(doc.Nodes), len(doc.Edges)
+		}
+		if nodes != 3 || edges != 4 {
+			t.Errorf("%s: got %d nodes and %d edges, want 3 and 4", format, nodes, edges)
+		}
+	}
+
+	if err := Write(new(bytes.Buffer), "csv", g, nil); err == nil {
+		t.Error("no error for unknown format")
+	}
+}
@@ -0,0 +1,372 @@
+// Package simnet implements an in-memory discovery network which can be used
+// in place of discv4/discv5 to test the crawler without touching the network.
+//
+// The network is a random directed graph of node records. Iterators returned
+// by RandomNodes walk the graph the way a lookup walks the DHT, and
+// RequestENR answers with the latest record of the node, subject to
+// configurable latency, failures and churn. Neighbors answers with the
+// outgoing edges of a node. All randomness is derived from Config.Seed, so
+// given the same configuration and the same sequence of calls the network
+// behaves identically.
+package simnet
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+var (
+	ErrUnknownNode = errors.New("unknown node")
+	ErrOffline     = errors.New("node offline")
+	ErrNoENR       = errors.New("node does not support ENR requests")
+	ErrTimeout     = errors.New("request timed out")
+)
+
+type Config struct {
+	// Number of nodes in the network.
+	Nodes int
+	// Number of outgoing edges of every node in the graph, i.e. the
+	// number of nodes returned when "asking" a node for its neighbours.
+	Degree int
+	// Seed for all randomness of the network.
+	Seed int64
+
+	// Latency is the base delay of every RequestENR call. LatencyJitter
+	// adds a uniformly distributed delay of up to the given duration.
+	Latency       time.Duration
+	LatencyJitter time.Duration
+
+	// Fraction of nodes which do not implement EIP-868, and never respond
+	// to ENR requests.
+	NoENRRatio float64
+	// Fraction of nodes which are offline at the start.
+	OfflineRatio float64
+	// Probability of any single ENR request to an online node failing.
+	FailureRate float64
+	// Fraction of nodes which change their online state on every call to
+	// Churn. Nodes which come back online publish a new record.
+	ChurnRate float64
+
+	// Maximum number of nodes returned by a RandomNodes iterator. Zero means
+	// the iterator runs until closed.
+	IteratorLimit int
+}
+
+type simNode struct {
+	key    []byte
+	node   *enode.Node
+	peers  []int
+	online bool
+	noENR  bool
+}
+
+// Network is a simulated discovery network. It is safe for concurrent use.
+type Network struct {
+	cfg Config
+
+	mu    sync.Mutex
+	rng   *rand.Rand
+	nodes []*simNode
+	byID  map[enode.ID]int
+
+	requests int
+}
+
+// New creates a network according to the config.
+func New(cfg Config) *Network {
+	if cfg.Degree <= 0 {
+		cfg.Degree = 16
+	}
+	if cfg.Nodes > 0 && cfg.Degree >= cfg.Nodes {
+		cfg.Degree = cfg.Nodes - 1
+	}
+
+	nw := &Network{
+		cfg:   cfg,
+		rng:   rand.New(rand.NewSource(cfg.Seed)),
+		nodes: make([]*simNode, cfg.Nodes),
+		byID:  make(map[enode.ID]int, cfg.Nodes),
+	}
+
+	for i := range nw.nodes {
+		key := make([]byte, 32)
+		nw.rng.Read(key)
+
+		sn := &simNode{
+			key:    key,
+			online: nw.rng.Float64() >= cfg.OfflineRatio,
+			noENR:  nw.rng.Float64() < cfg.NoENRRatio,
+		}
+		sn.node = makeNode(key, i, 1)
+		nw.nodes[i] = sn
+		nw.byID[sn.node.ID()] = i
+	}
+
+	for i, sn := range nw.nodes {
+		sn.peers = make([]int, 0, cfg.Degree)
+		for _, p := range nw.rng.Perm(cfg.Nodes) {
+			if len(sn.peers) == cfg.Degree {
+				break
+			}
+			if p != i {
+				sn.peers = append(sn.peers, p)
+			}
+		}
+	}
+
+	return nw
+}
+
+// makeNode creates a signed record for the node with the given index and
+// sequence number. The node's address is derived from the index.
+func makeNode(keyBytes []byte, index int, seq uint64) *enode.Node {
+	key, err := crypto.ToECDSA(keyBytes)
+	if err != nil {
+		panic(err)
+	}
+
+	var r enr.Record
+	r.Set(enr.IPv4(net.IPv4(10, byte(index>>16), byte(index>>8), byte(index))))
+	r.Set(enr.UDP(30303))
+	r.Set(enr.TCP(30303))
+	r.SetSeq(seq)
+	if err := enode.SignV4(&r, key); err != nil {
+		panic(err)
+	}
+
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// Nodes returns the current records of all nodes in the network, online or not.
+func (nw *Network) Nodes() []*enode.Node {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	nodes := make([]*enode.Node, len(nw.nodes))
+	for i, sn := range nw.nodes {
+		nodes[i] = sn.node
+	}
+	return nodes
+}
+
+// Online reports whether the node with the given ID is currently online.
+func (nw *Network) Online(id enode.ID) bool {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	i, ok := nw.byID[id]
+	return ok && nw.nodes[i].online
+}
+
+// SetOnline brings the node with the given ID online or takes it offline.
+func (nw *Network) SetOnline(id enode.ID, online bool) {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	if i, ok := nw.byID[id]; ok {
+		nw.setOnline(i, online)
+	}
+}
+
+func (nw *Network) setOnline(i int, online bool) {
+	sn := nw.nodes[i]
+	if sn.online == online {
+		return
+	}
+	sn.online = online
+	// A node coming back online publishes an updated record.
+	if online {
+		sn.node = makeNode(sn.key, i, sn.node.Seq()+1)
+	}
+}
+
+// Churn flips the online state of a ChurnRate fraction of the nodes.
+// It returns the number of nodes affected.
+func (nw *Network) Churn() int {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	var changed int
+	for i, sn := range nw.nodes {
+		if nw.rng.Float64() < nw.cfg.ChurnRate {
+			nw.setOnline(i, !sn.online)
+			changed++
+		}
+	}
+	return changed
+}
+
+// Requests returns the number of ENR requests served so far.
+func (nw *Network) Requests() int {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	return nw.requests
+}
+
+// RequestENR returns the latest record of the node.
+func (nw *Network) RequestENR(n *enode.Node) (*enode.Node, error) {
+	nw.mu.Lock()
+	nw.requests++
+	delay := nw.cfg.Latency
+	if nw.cfg.LatencyJitter > 0 {
+		delay += time.Duration(nw.rng.Int63n(int64(nw.cfg.LatencyJitter)))
+	}
+	fail := nw.rng.Float64() < nw.cfg.FailureRate
+
+	i, ok := nw.byID[n.ID()]
+	var (
+		sn     *simNode
+		record *enode.Node
+		online bool
+	)
+	if ok {
+		sn = nw.nodes[i]
+		record, online = sn.node, sn.online
+	}
+	nw.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	switch {
+	case !ok:
+		return nil, ErrUnknownNode
+	case !online:
+		return nil, ErrOffline
+	case sn.noENR:
+		return nil, ErrNoENR
+	case fail:
+		return nil, ErrTimeout
+	}
+	return record, nil
+}
+
+// Neighbors returns the peers of the node at the given log-distance from it,
+// like a query of one bucket of its routing table. Offline nodes don't
+// answer.
+func (nw *Network) Neighbors(n *enode.Node, distance uint) ([]*enode.Node, error) {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	i, ok := nw.byID[n.ID()]
+	switch {
+	case !ok:
+		return nil, ErrUnknownNode
+	case !nw.nodes[i].online:
+		return nil, ErrOffline
+	}
+	var nodes []*enode.Node
+	for _, p := range nw.nodes[i].peers {
+		peer := nw.nodes[p].node
+		if uint(enode.LogDist(n.ID(), peer.ID())) == distance {
+			nodes = append(nodes, peer)
+		}
+	}
+	return nodes, nil
+}
+
+// RandomNodes returns an iterator which walks the graph, starting at a random
+// node. Like the routing tables of a real DHT, the neighbour lists contain
+// offline nodes as well.
+func (nw *Network) RandomNodes() enode.Iterator {
+	nw.mu.Lock()
+	seed := nw.rng.Int63()
+	nw.mu.Unlock()
+
+	return &walkIterator{
+		nw:     nw,
+		rng:    rand.New(rand.NewSource(seed)),
+		limit:  nw.cfg.IteratorLimit,
+		closed: make(chan struct{}),
+	}
+}
+
+// walkIterator walks the graph breadth-first. Every node it returns is also
+// asked for its neighbours later, if it is online. Once no node is left to
+// ask, the walk starts over at the neighbours of a random node.
+type walkIterator struct {
+	nw    *Network
+	rng   *rand.Rand
+	limit int
+
+	buf []int
+	// frontier are the nodes to ask next, and asked those asked since the
+	// walk started.
+	frontier []int
+	asked    map[int]bool
+	cur      *enode.Node
+	count    int
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (it *walkIterator) Next() bool {
+	select {
+	case <-it.closed:
+		return false
+	default:
+	}
+	if it.limit > 0 && it.count >= it.limit {
+		return false
+	}
+
+	it.nw.mu.Lock()
+	defer it.nw.mu.Unlock()
+
+	// Without edges, there is nothing to walk.
+	if it.nw.cfg.Degree == 0 || len(it.nw.nodes) < 2 {
+		return false
+	}
+	for len(it.buf) == 0 {
+		it.ask()
+	}
+	next := it.buf[0]
+	it.buf = it.buf[1:]
+
+	it.cur = it.nw.nodes[next].node
+	it.count++
+	return true
+}
+
+// ask "asks" the next node of the walk for its neighbours. Offline nodes
+// don't answer. The lock must be held.
+func (it *walkIterator) ask() {
+	var peers []int
+	if len(it.frontier) == 0 {
+		start := it.rng.Intn(len(it.nw.nodes))
+		it.asked = map[int]bool{start: true}
+		peers = it.nw.nodes[start].peers
+	} else {
+		i := it.frontier[0]
+		it.frontier = it.frontier[1:]
+		if it.asked[i] || !it.nw.nodes[i].online {
+			return
+		}
+		it.asked[i] = true
+		peers = it.nw.nodes[i].peers
+	}
+	it.buf = append(it.buf, peers...)
+	it.frontier = append(it.frontier, peers...)
+}
+
+func (it *walkIterator) Node() *enode.Node {
+	return it.cur
+}
+
+func (it *walkIterator) Close() {
+	it.closeOnce.Do(func() { close(it.closed) })
+}
@@ -0,0 +1,141 @@
+package simnet
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestDeterministic(t *testing.T) {
+	cfg := Config{
+		Nodes:         500,
+		Seed:          42,
+		NoENRRatio:    0.1,
+		OfflineRatio:  0.2,
+		FailureRate:   0.05,
+		IteratorLimit: 1000,
+	}
+	run := func() ([]enode.ID, []bool) {
+		nw := New(cfg)
+		it := nw.RandomNodes()
+		defer it.Close()
+
+		var (
+			ids []enode.ID
+			ok  []bool
+		)
+		for it.Next() {
+			_, err := nw.RequestENR(it.Node())
+			ids = append(ids, it.Node().ID())
+			ok = append(ok, err == nil)
+		}
+		return ids, ok
+	}
+
+	ids1, ok1 := run()
+	ids2, ok2 := run()
+	if len(ids1) != cfg.IteratorLimit {
+		t.Fatalf("wrong number of nodes from iterator: got %d, want %d", len(ids1), cfg.IteratorLimit)
+	}
+	for i := range ids1 {
+		if ids1[i] != ids2[i] || ok1[i] != ok2[i] {
+			t.Fatalf("runs differ at position %d", i)
+		}
+	}
+}
+
+func TestRequestENR(t *testing.T) {
+	nw := New(Config{Nodes: 1000, Seed: 1, NoENRRatio: 0.25, OfflineRatio: 0.25})
+
+	var failed int
+	for _, n := range nw.Nodes() {
+		if _, err := nw.RequestENR(n); err != nil {
+			failed++
+		}
+	}
+	// About 1 - 0.75*0.75 of the nodes should fail.
+	if failed < 380 || failed > 500 {
+		t.Fatalf("unexpected number of failed requests: %d", failed)
+	}
+}
+
+func TestChurn(t *testing.T) {
+	nw := New(Config{Nodes: 100, Seed: 1, ChurnRate: 1})
+	n := nw.Nodes()[0]
+
+	if changed := nw.Churn(); changed != 100 {
+		t.Fatalf("wrong number of churned nodes: got %d, want 100", changed)
+	}
+	if nw.Online(n.ID()) {
+		t.Fatal("node still online after churn")
+	}
+	if _, err := nw.RequestENR(n); err != ErrOffline {
+		t.Fatalf("wrong error for offline node: %v", err)
+	}
+
+	nw.Churn()
+	rec, err := nw.RequestENR(n)
+	if err != nil {
+		t.Fatalf("request failed after node came back: %v", err)
+	}
+	if rec.Seq() != n.Seq()+1 {
+		t.Fatalf("wrong seq after node came back: got %d, want %d", rec.Seq(), n.Seq()+1)
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	nw := New(Config{Nodes: 100, Seed: 1})
+	n := nw.Nodes()[0]
+
+	var total int
+	for d := uint(0); d <= 256; d++ {
+		peers, err := nw.Neighbors(n, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range peers {
+			if got := uint(enode.LogDist(n.ID(), p.ID())); got != d {
+				t.Errorf("peer %v at distance %d returned for distance %d", p.ID(), got, d)
+			}
+		}
+		total += len(peers)
+	}
+	if total != 16 {
+		t.Errorf("got %d neighbors, want 16", total)
+	}
+
+	nw.SetOnline(n.ID(), false)
+	if _, err := nw.Neighbors(n, 256); err != ErrOffline {
+		t.Fatalf("wrong error for offline node: %v", err)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	nw := New(Config{Nodes: 500, Seed: 1, OfflineRatio: 0.2, IteratorLimit: 2000})
+	it := nw.RandomNodes()
+	defer it.Close()
+
+	// Past the neighbours of the start node, every node is a neighbour of
+	// an online node returned earlier.
+	var (
+		reachable = make(map[enode.ID]bool)
+		seen      = make(map[enode.ID]bool)
+		count     int
+	)
+	for it.Next() {
+		n := it.Node()
+		if count >= nw.cfg.Degree && !reachable[n.ID()] {
+			t.Fatalf("node %d not found by walking the graph", count)
+		}
+		if i := nw.byID[n.ID()]; nw.nodes[i].online {
+			for _, p := range nw.nodes[i].peers {
+				reachable[nw.nodes[p].node.ID()] = true
+			}
+		}
+		seen[n.ID()] = true
+		count++
+	}
+	if len(seen) < 400 {
+		t.Errorf("walk only reached %d nodes", len(seen))
+	}
+}
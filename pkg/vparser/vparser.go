@@ -84,6 +84,28 @@ func ParseVersionString(input string) *ParsedInfo {
 	return &output
 }
 
+// ParseENRClient parses the client identity of the EIP-7636 "client" entry of
+// a node record, given as "name/version" or "name/version/build". Unlike the
+// Hello version string, it carries no OS or language.
+func ParseENRClient(input string) *ParsedInfo {
+	s := strings.Split(strings.ToLower(input), "/")
+	if len(s) < 2 || len(s) > 3 || s[0] == "" {
+		return nil
+	}
+
+	output := ParsedInfo{
+		Name:    s[0],
+		Version: parseVersion(s[1]),
+	}
+	if output.Version.Error {
+		return nil
+	}
+	if len(s) == 3 && s[2] != "" {
+		output.Version.Build = s[2]
+	}
+	return &output
+}
+
 func parseLanguage(input string) LanguageInfo {
 	var languageInfo LanguageInfo
 	match := reLanguage.FindStringSubmatch(input)
@@ -150,3 +150,34 @@ func TestParseVersionString(t *testing.T) {
 		})
 	}
 }
+
+func TestParseENRClient(t *testing.T) {
+	tests := []struct {
+		args string
+		want *ParsedInfo
+	}{
+		{
+			args: "Geth/1.15.9/7d8aca95",
+			want: &ParsedInfo{
+				Name:    "geth",
+				Version: Version{Major: 1, Minor: 15, Patch: 9, Build: "7d8aca95"},
+			},
+		},
+		{
+			args: "Reth/v1.3.12-dev",
+			want: &ParsedInfo{
+				Name:    "reth",
+				Version: Version{Major: 1, Minor: 3, Patch: 12, Tag: "dev"},
+			},
+		},
+		{args: "Geth", want: nil},
+		{args: "/1.0.0", want: nil},
+		{args: "Geth/unstable", want: nil},
+		{args: "Geth/1.15.9/abc/def", want: nil},
+	}
+	for _, tt := range tests {
+		if got := ParseENRClient(tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseENRClient(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}